@@ -0,0 +1,16 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// apiSpec is the OpenAPI document describing the service, kept in sync with the handlers by the concordances tests
+//
+//go:embed api/api.yml
+var apiSpec []byte
+
+func apiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/yaml; charset=UTF-8")
+	w.Write(apiSpec)
+}
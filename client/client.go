@@ -0,0 +1,277 @@
+// Package client is a Go client for the public concordances API
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/public-concordances-api/concordances"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+)
+
+const (
+	defaultBatchSize      = 50
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 2 * time.Second
+)
+
+// Concordances is implemented by Client, and by Fake for the tests of code that uses it
+type Concordances interface {
+	ByConceptIDs(ctx context.Context, conceptIDs []string) (concordances.Concordances, error)
+	ByAuthority(ctx context.Context, authority string, identifierValues []string) (concordances.Concordances, error)
+}
+
+var (
+	_ Concordances = (*Client)(nil)
+	_ Concordances = (*Fake)(nil)
+)
+
+// Client calls the /concordances endpoint, splitting large lookups into batches and retrying failed requests
+type Client struct {
+	baseURL        string
+	httpClient     *http.Client
+	batchSize      int
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	apiKey         string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey sends the key in the X-Api-Key header of every request
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithBatchSize sets the largest number of IDs or identifier values sent in a single request
+func WithBatchSize(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
+}
+
+// WithRetries sets how many times a request is attempted, and the backoff between attempts, which doubles
+// after each failure up to maxBackoff. Only network errors, 429s and 5xx responses are retried.
+func WithRetries(maxAttempts int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		if maxAttempts > 0 {
+			c.maxAttempts = maxAttempts
+		}
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a Client for the API at baseURL, e.g. http://public-concordances-api:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		httpClient:     http.DefaultClient,
+		batchSize:      defaultBatchSize,
+		maxAttempts:    defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StatusError is returned when the API responds with an unexpected status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("concordances API responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// ByConceptIDs returns every identifier of each concept, given as UUIDs or http://api.ft.com/things/ URIs
+func (c *Client) ByConceptIDs(ctx context.Context, conceptIDs []string) (concordances.Concordances, error) {
+	return c.lookup(ctx, conceptIDs, func(batch []string) url.Values {
+		return url.Values{"conceptId": batch}
+	})
+}
+
+// ByAuthority returns the concepts for identifier values in a single authority, e.g. http://api.ft.com/system/FT-TME
+func (c *Client) ByAuthority(ctx context.Context, authority string, identifierValues []string) (concordances.Concordances, error) {
+	return c.lookup(ctx, identifierValues, func(batch []string) url.Values {
+		return url.Values{"authority": {authority}, "identifierValue": batch}
+	})
+}
+
+// lookup requests the values in batches, merging the results in the API's default order.
+// Every batch is sent with the same transaction ID, taken from ctx if it carries one.
+func (c *Client) lookup(ctx context.Context, values []string, query func([]string) url.Values) (concordances.Concordances, error) {
+	result := concordances.Concordances{Concordance: []concordances.Concordance{}}
+	if len(values) == 0 {
+		return result, nil
+	}
+
+	tid, err := transactionidutils.GetTransactionIDFromContext(ctx)
+	if err != nil || tid == "" {
+		tid = transactionidutils.NewTransactionID()
+	}
+
+	for start := 0; start < len(values); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		batch, err := c.getWithRetries(ctx, tid, query(values[start:end]))
+		if err != nil {
+			return concordances.Concordances{}, err
+		}
+		result.Concordance = merge(result.Concordance, batch.Concordance)
+	}
+
+	concordances.SortConcordances(result.Concordance, concordances.DefaultSortOrder)
+	return result, nil
+}
+
+func (c *Client) getWithRetries(ctx context.Context, tid string, query url.Values) (concordances.Concordances, error) {
+	backoff := c.initialBackoff
+	for attempt := 1; ; attempt++ {
+		result, retryAfter, err := c.get(ctx, tid, query)
+		if err == nil || retryAfter < 0 || attempt >= c.maxAttempts {
+			return result, err
+		}
+
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if wait > 0 {
+			// Up to 20% jitter stops clients that failed together retrying together
+			wait += time.Duration(rand.Int63n(int64(wait)/5 + 1))
+		}
+		select {
+		case <-ctx.Done():
+			return concordances.Concordances{}, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// get makes a single request. retryAfter is negative if a failed request shouldn't be retried, otherwise it is the
+// minimum time the server asked us to wait.
+func (c *Client) get(ctx context.Context, tid string, query url.Values) (result concordances.Concordances, retryAfter time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/concordances?"+query.Encode(), nil)
+	if err != nil {
+		return result, -1, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(transactionidutils.TransactionIDHeader, tid)
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-Api-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, -1, ctx.Err()
+		}
+		return result, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		return result, -1, err
+	}
+
+	err = &StatusError{StatusCode: resp.StatusCode, Message: readMessage(resp)}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return result, parseRetryAfter(resp.Header.Get("Retry-After")), err
+	}
+	return result, -1, err
+}
+
+func readMessage(resp *http.Response) string {
+	body, _ := ioutil.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &msg) == nil && msg.Message != "" {
+		return msg.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type concordanceKey struct {
+	conceptID string
+	authority string
+	value     string
+}
+
+// merge appends the concordances in batch to merged. A concept requested by different IDs in different batches is
+// returned by each of them, so duplicates are dropped and their requestedIds and mergedFrom combined.
+func merge(merged, batch []concordances.Concordance) []concordances.Concordance {
+	seen := make(map[concordanceKey]int, len(merged))
+	for i, c := range merged {
+		seen[concordanceKey{c.Concept.ID, c.Identifier.Authority, c.Identifier.IdentifierValue}] = i
+	}
+	for _, c := range batch {
+		key := concordanceKey{c.Concept.ID, c.Identifier.Authority, c.Identifier.IdentifierValue}
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(merged)
+			merged = append(merged, c)
+			continue
+		}
+		for _, id := range c.Concept.RequestedIDs {
+			merged[i].Concept.RequestedIDs = appendUnique(merged[i].Concept.RequestedIDs, id)
+		}
+		for _, id := range c.Concept.MergedFrom {
+			merged[i].Concept.MergedFrom = appendUnique(merged[i].Concept.MergedFrom, id)
+		}
+	}
+	return merged
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
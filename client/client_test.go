@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/public-concordances-api/concordances"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tmeBrand = concordances.Concordance{
+		Concept: concordances.Concept{
+			ID:     "http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+			APIURL: "http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		Identifier: concordances.Identifier{
+			Authority:       "http://api.ft.com/system/FT-TME",
+			IdentifierValue: "VGhlIFJvbWFu-QnJhbmRz"},
+	}
+	smartlogicBrand = concordances.Concordance{
+		Concept: concordances.Concept{
+			ID:     "http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+			APIURL: "http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		Identifier: concordances.Identifier{
+			Authority:       "http://api.ft.com/system/SMARTLOGIC",
+			IdentifierValue: "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+	}
+)
+
+// recordingServer answers each request with the next handler in responses, recording the requests it receives
+type recordingServer struct {
+	mu        sync.Mutex
+	requests  []*http.Request
+	responses []http.HandlerFunc
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := len(s.requests)
+	s.requests = append(s.requests, r)
+	s.mu.Unlock()
+	s.responses[n%len(s.responses)](w, r)
+}
+
+func respondWith(status int, body interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func TestByConceptIDsSplitsRequestsIntoBatchesAndMergesResults(t *testing.T) {
+	assert := assert.New(t)
+	first := smartlogicBrand
+	first.Concept.RequestedIDs = []string{"http://api.ft.com/things/old-1"}
+	second := smartlogicBrand
+	second.Concept.RequestedIDs = []string{"http://api.ft.com/things/old-2"}
+	rs := &recordingServer{responses: []http.HandlerFunc{
+		respondWith(200, concordances.Concordances{Concordance: []concordances.Concordance{first}}),
+		respondWith(200, concordances.Concordances{Concordance: []concordances.Concordance{second, tmeBrand}}),
+	}}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	result, err := New(srv.URL, WithBatchSize(2)).ByConceptIDs(context.Background(), []string{"a", "old-1", "old-2"})
+	assert.NoError(err)
+	if assert.Len(rs.requests, 2) {
+		assert.Equal([]string{"a", "old-1"}, rs.requests[0].URL.Query()["conceptId"])
+		assert.Equal([]string{"old-2"}, rs.requests[1].URL.Query()["conceptId"])
+		assert.NotEmpty(rs.requests[0].Header.Get("X-Request-Id"))
+		assert.Equal(rs.requests[0].Header.Get("X-Request-Id"), rs.requests[1].Header.Get("X-Request-Id"))
+	}
+	if assert.Len(result.Concordance, 2) {
+		assert.Equal(tmeBrand, result.Concordance[0])
+		assert.Equal([]string{"http://api.ft.com/things/old-1", "http://api.ft.com/things/old-2"}, result.Concordance[1].Concept.RequestedIDs)
+	}
+}
+
+func TestByAuthorityRetriesServerErrors(t *testing.T) {
+	assert := assert.New(t)
+	rs := &recordingServer{responses: []http.HandlerFunc{
+		respondWith(503, map[string]string{"message": "unavailable"}),
+		respondWith(200, concordances.Concordances{Concordance: []concordances.Concordance{tmeBrand}}),
+	}}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	result, err := New(srv.URL, WithRetries(3, time.Millisecond, time.Millisecond)).
+		ByAuthority(context.Background(), "http://api.ft.com/system/FT-TME", []string{"VGhlIFJvbWFu-QnJhbmRz"})
+	assert.NoError(err)
+	assert.Len(rs.requests, 2)
+	assert.Equal("http://api.ft.com/system/FT-TME", rs.requests[1].URL.Query().Get("authority"))
+	assert.Equal([]concordances.Concordance{tmeBrand}, result.Concordance)
+}
+
+func TestClientDoesNotRetryBadRequests(t *testing.T) {
+	assert := assert.New(t)
+	rs := &recordingServer{responses: []http.HandlerFunc{
+		respondWith(400, map[string]string{"message": "Multiple authorities are not permitted"}),
+	}}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithRetries(3, time.Millisecond, time.Millisecond)).ByConceptIDs(context.Background(), []string{"a"})
+	var statusErr *StatusError
+	if assert.True(errors.As(err, &statusErr)) {
+		assert.Equal(400, statusErr.StatusCode)
+		assert.Equal("Multiple authorities are not permitted", statusErr.Message)
+	}
+	assert.Len(rs.requests, 1)
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	assert := assert.New(t)
+	rs := &recordingServer{responses: []http.HandlerFunc{respondWith(500, map[string]string{"message": "boom"})}}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithRetries(2, time.Millisecond, time.Millisecond)).ByConceptIDs(context.Background(), []string{"a"})
+	assert.Error(err)
+	assert.Len(rs.requests, 2)
+}
+
+func TestFakeFiltersLikeTheAPI(t *testing.T) {
+	assert := assert.New(t)
+	fake := NewFake(tmeBrand, smartlogicBrand)
+
+	result, err := fake.ByConceptIDs(context.Background(), []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
+	assert.NoError(err)
+	assert.Equal([]concordances.Concordance{tmeBrand, smartlogicBrand}, result.Concordance)
+
+	result, err = fake.ByAuthority(context.Background(), "http://api.ft.com/system/SMARTLOGIC", []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
+	assert.NoError(err)
+	assert.Equal([]concordances.Concordance{smartlogicBrand}, result.Concordance)
+
+	fake.Err = errors.New("unavailable")
+	_, err = fake.ByConceptIDs(context.Background(), []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
+	assert.EqualError(err, "unavailable")
+	assert.Equal(3, fake.Calls())
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/public-concordances-api/concordances"
+)
+
+const thingURIPrefix = "http://api.ft.com/things/"
+
+// Fake answers lookups from an in-memory set of concordances, the way the API would
+type Fake struct {
+	// Err, if set, is returned by every lookup instead of a result
+	Err error
+
+	mu           sync.Mutex
+	concordances []concordances.Concordance
+	calls        int
+}
+
+// NewFake returns a Fake holding the given concordances
+func NewFake(c ...concordances.Concordance) *Fake {
+	return &Fake{concordances: c}
+}
+
+// Add stores more concordances
+func (f *Fake) Add(c ...concordances.Concordance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.concordances = append(f.concordances, c...)
+}
+
+// Calls returns how many lookups have been made
+func (f *Fake) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// ByConceptIDs returns the stored concordances whose concept ID is one of conceptIDs
+func (f *Fake) ByConceptIDs(ctx context.Context, conceptIDs []string) (concordances.Concordances, error) {
+	wanted := map[string]bool{}
+	for _, id := range conceptIDs {
+		wanted[thingURIPrefix+strings.TrimPrefix(id, thingURIPrefix)] = true
+	}
+	return f.filter(func(c concordances.Concordance) bool {
+		return wanted[c.Concept.ID]
+	})
+}
+
+// ByAuthority returns the stored concordances for identifierValues in authority
+func (f *Fake) ByAuthority(ctx context.Context, authority string, identifierValues []string) (concordances.Concordances, error) {
+	wanted := map[string]bool{}
+	for _, v := range identifierValues {
+		wanted[v] = true
+	}
+	return f.filter(func(c concordances.Concordance) bool {
+		return c.Identifier.Authority == authority && wanted[c.Identifier.IdentifierValue]
+	})
+}
+
+func (f *Fake) filter(match func(concordances.Concordance) bool) (concordances.Concordances, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.Err != nil {
+		return concordances.Concordances{}, f.Err
+	}
+
+	result := concordances.Concordances{Concordance: []concordances.Concordance{}}
+	for _, c := range f.concordances {
+		if match(c) {
+			result.Concordance = append(result.Concordance, c)
+		}
+	}
+	concordances.SortConcordances(result.Concordance, concordances.DefaultSortOrder)
+	return result, nil
+}
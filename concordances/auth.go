@@ -0,0 +1,185 @@
+package concordances
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Financial-Times/go-logger"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/rcrowley/go-metrics"
+)
+
+// ErrNoCredentials is returned by an Authenticator when a request doesn't carry the kind of credentials it checks
+var ErrNoCredentials = errors.New("no credentials provided")
+
+var (
+	errInvalidAPIKey = errors.New("invalid API key")
+	errUnknownClient = errors.New("unknown client")
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+var rejectedRequests = metrics.GetOrRegisterCounter("concordances.auth.rejected", metrics.DefaultRegistry)
+
+// ClientIdentity is the authenticated caller of a request
+type ClientIdentity struct {
+	ID string
+	// AllowedAuthorities restricts the authorities the client can look up and see identifiers in. Empty allows all.
+	AllowedAuthorities []string
+}
+
+// CanSee reports whether the client is allowed identifiers in the authority
+func (c *ClientIdentity) CanSee(authority string) bool {
+	if c == nil || len(c.AllowedAuthorities) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedAuthorities {
+		if allowed == authority {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the client making a request. It returns ErrNoCredentials if the request has none of
+// the credentials it understands, so that the next Authenticator in an AuthenticatorChain can be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*ClientIdentity, error)
+}
+
+// AuthenticatorChain tries each Authenticator in turn, until one finds credentials in the request
+type AuthenticatorChain []Authenticator
+
+// Authenticate returns the result of the first Authenticator to find credentials
+func (chain AuthenticatorChain) Authenticate(r *http.Request) (*ClientIdentity, error) {
+	for _, a := range chain {
+		client, err := a.Authenticate(r)
+		if err != ErrNoCredentials {
+			return client, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// clientConfig is an entry in the clients file
+type clientConfig struct {
+	ID                 string   `json:"id"`
+	APIKeys            []string `json:"apiKeys"`
+	AllowedAuthorities []string `json:"allowedAuthorities"`
+}
+
+// Clients are the known callers of the API, loaded from a JSON file of the form
+//
+//	{"clients": [{"id": "...", "apiKeys": ["..."], "allowedAuthorities": ["http://api.ft.com/system/FT-TME"]}]}
+type Clients struct {
+	byID     map[string]*ClientIdentity
+	byAPIKey map[[sha256.Size]byte]*ClientIdentity
+}
+
+// LoadClients reads the clients file at path
+func LoadClients(path string) (*Clients, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Clients []clientConfig `json:"clients"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing clients file %s: %v", path, err)
+	}
+
+	clients := &Clients{
+		byID:     map[string]*ClientIdentity{},
+		byAPIKey: map[[sha256.Size]byte]*ClientIdentity{},
+	}
+	for _, c := range file.Clients {
+		if c.ID == "" {
+			return nil, fmt.Errorf("client without an id in %s", path)
+		}
+		if _, ok := clients.byID[c.ID]; ok {
+			return nil, fmt.Errorf("client %s is listed more than once in %s", c.ID, path)
+		}
+		identity := &ClientIdentity{ID: c.ID, AllowedAuthorities: c.AllowedAuthorities}
+		clients.byID[c.ID] = identity
+		for _, key := range c.APIKeys {
+			// Keys are only held hashed, so map lookups don't leak timing information about them
+			clients.byAPIKey[sha256.Sum256([]byte(key))] = identity
+		}
+	}
+	return clients, nil
+}
+
+// Lookup returns the client with the given ID
+func (c *Clients) Lookup(id string) (*ClientIdentity, bool) {
+	client, ok := c.byID[id]
+	return client, ok
+}
+
+// APIKeyAuthenticator identifies clients by the API key in the X-Api-Key header
+type APIKeyAuthenticator struct {
+	clients *Clients
+}
+
+// NewAPIKeyAuthenticator returns an Authenticator for the API keys of clients
+func NewAPIKeyAuthenticator(clients *Clients) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{clients: clients}
+}
+
+// Authenticate looks up the client the request's API key belongs to
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*ClientIdentity, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	client, ok := a.clients.byAPIKey[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+	return client, nil
+}
+
+// AuthMiddleware rejects requests the Authenticator can't identify the client of, with a 401. Identified clients
+// are available to the handlers, which restrict them to their allowed authorities, and are counted per client.
+func AuthMiddleware(auth Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := auth.Authenticate(r)
+		if err != nil {
+			rejectedRequests.Inc(1)
+			log.WithTransactionID(transactionidutils.GetTransactionIDFromRequest(r)).
+				WithField("path", r.URL.Path).
+				WithError(err).
+				Warn("Rejected unauthenticated request")
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="public-concordances-api"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "` + authenticationRequired + `"}`))
+			return
+		}
+
+		markClientRequest(client)
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), client)))
+	})
+}
+
+// markClientRequest counts a request or gRPC call by the client that made it
+func markClientRequest(client *ClientIdentity) {
+	metrics.GetOrRegisterMeter("concordances.requests.client."+client.ID, metrics.DefaultRegistry).Mark(1)
+}
+
+type clientKey struct{}
+
+func withClient(ctx context.Context, client *ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFromContext returns the authenticated client, or nil if authentication is disabled
+func clientFromContext(ctx context.Context) *ClientIdentity {
+	client, _ := ctx.Value(clientKey{}).(*ClientIdentity)
+	return client
+}
@@ -0,0 +1,134 @@
+package concordances
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientsFile = `{"clients": [
+	{"id": "search-indexing", "apiKeys": ["search-key"]},
+	{"id": "partner", "apiKeys": ["partner-key"], "allowedAuthorities": ["http://api.ft.com/system/FT-TME", "http://api.ft.com/system/UPP"]}
+]}`
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func newAuthServer(t *testing.T, auth Authenticator) *httptest.Server {
+	s := httptest.NewServer(AuthMiddleware(auth, newTestRouter(mockConcordanceDriver{})))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func getWithHeader(t *testing.T, url string, header string, value string) *http.Response {
+	req, _ := http.NewRequest("GET", url, nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return res
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	assert := assert.New(t)
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	s := newAuthServer(t, NewAPIKeyAuthenticator(clients))
+	url := s.URL + "/concordances?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad"
+
+	res := getWithHeader(t, url, "", "")
+	assert.Equal(http.StatusUnauthorized, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), authenticationRequired)
+
+	assert.Equal(http.StatusUnauthorized, getWithHeader(t, url, "X-Api-Key", "wrong-key").StatusCode)
+	assert.Equal(http.StatusOK, getWithHeader(t, url, "X-Api-Key", "search-key").StatusCode)
+}
+
+func TestClientOnlySeesAllowedAuthorities(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME, concordedBrandTMEUPP}}
+
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	s := newAuthServer(t, NewAPIKeyAuthenticator(clients))
+
+	res := getWithHeader(t, s.URL+"/concordances?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad", "X-Api-Key", "partner-key")
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("max-age=60, private", res.Header.Get("Cache-Control"), "filtered responses mustn't be shared between clients")
+	var partnerView Concordances
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&partnerView))
+	assert.Equal([]Concordance{concordedBrandTME, concordedBrandTMEUPP}, partnerView.Concordance)
+
+	res = getWithHeader(t, s.URL+"/concordances?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad", "X-Api-Key", "search-key")
+	var fullView Concordances
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&fullView))
+	assert.Len(fullView.Concordance, 3)
+
+	res = getWithHeader(t, s.URL+"/concordances?authority=http://api.ft.com/system/SMARTLOGIC&identifierValue=x", "X-Api-Key", "partner-key")
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+	res = getWithHeader(t, s.URL+"/authorities/SMARTLOGIC/identifiers/x", "X-Api-Key", "partner-key")
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func TestLoadClientsRejectsDuplicateIDs(t *testing.T) {
+	_, err := LoadClients(writeTestFile(t, "clients.json", `{"clients": [{"id": "a"}, {"id": "a"}]}`))
+	assert.Error(t, err)
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	assert := assert.New(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kid": "test-key",
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	jwtAuth, err := NewJWTAuthenticator(writeTestFile(t, "jwks.json", string(jwks)), clients, "https://issuer.ft.com", "public-concordances-api")
+	require.NoError(t, err)
+	s := newAuthServer(t, AuthenticatorChain{NewAPIKeyAuthenticator(clients), jwtAuth})
+	url := s.URL + "/concordances?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad"
+
+	sign := func(subject string, expires time.Time, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": subject,
+			"iss": "https://issuer.ft.com",
+			"aud": []string{"public-concordances-api"},
+			"exp": expires.Unix(),
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+
+	assert.Equal(http.StatusOK, getWithHeader(t, url, "Authorization", sign("partner", time.Now().Add(time.Hour), "test-key")).StatusCode)
+	assert.Equal(http.StatusUnauthorized, getWithHeader(t, url, "Authorization", sign("partner", time.Now().Add(-time.Hour), "test-key")).StatusCode)
+	assert.Equal(http.StatusUnauthorized, getWithHeader(t, url, "Authorization", sign("unknown", time.Now().Add(time.Hour), "test-key")).StatusCode)
+	assert.Equal(http.StatusUnauthorized, getWithHeader(t, url, "Authorization", sign("partner", time.Now().Add(time.Hour), "other-key")).StatusCode)
+	assert.Equal(http.StatusOK, getWithHeader(t, url, "X-Api-Key", "search-key").StatusCode)
+}
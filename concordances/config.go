@@ -0,0 +1,217 @@
+package concordances
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/rcrowley/go-metrics"
+	"github.com/sirupsen/logrus"
+)
+
+var configReloadFailures = metrics.GetOrRegisterCounter("concordances.config.reload_failures", metrics.DefaultRegistry)
+
+// secretSetting matches the names of startup settings whose values are never shown by the config endpoint
+var secretSetting = regexp.MustCompile(`(?i)(password|secret|token|api_?key)`)
+
+const redacted = "REDACTED"
+
+// RuntimeConfig holds the settings that can be changed without restarting the service. Settings missing from the
+// config file keep their startup values.
+type RuntimeConfig struct {
+	// CacheDuration is how long responses may be cached for, e.g. 10m
+	CacheDuration string `json:"cacheDuration,omitempty"`
+	LogLevel      string `json:"logLevel,omitempty"`
+	// Authorities adds to, or changes the URIs of, the authorities identifiers are concorded from
+	Authorities    map[string]string `json:"authorities,omitempty"`
+	RateLimit      *int              `json:"rateLimit,omitempty"`
+	RateLimitBurst *int              `json:"rateLimitBurst,omitempty"`
+}
+
+// merge returns c with the settings present in override replacing its own
+func (c RuntimeConfig) merge(override RuntimeConfig) RuntimeConfig {
+	if override.CacheDuration != "" {
+		c.CacheDuration = override.CacheDuration
+	}
+	if override.LogLevel != "" {
+		c.LogLevel = override.LogLevel
+	}
+	if override.Authorities != nil {
+		c.Authorities = override.Authorities
+	}
+	if override.RateLimit != nil {
+		c.RateLimit = override.RateLimit
+	}
+	if override.RateLimitBurst != nil {
+		c.RateLimitBurst = override.RateLimitBurst
+	}
+	return c
+}
+
+// validatedConfig holds the parsed settings, so that a config is either applied in full or not at all
+type validatedConfig struct {
+	cacheDuration time.Duration
+	logLevel      logrus.Level
+}
+
+func (c RuntimeConfig) validate() (validatedConfig, error) {
+	var v validatedConfig
+	var err error
+	if v.cacheDuration, err = time.ParseDuration(c.CacheDuration); err != nil {
+		return v, fmt.Errorf("invalid cacheDuration: %v", err)
+	}
+	if v.logLevel, err = logrus.ParseLevel(c.LogLevel); err != nil {
+		return v, fmt.Errorf("invalid logLevel: %v", err)
+	}
+	for authority, uri := range c.Authorities {
+		if !strings.HasPrefix(uri, authorityURIPrefix) {
+			return v, fmt.Errorf("URI of authority %s must start with %s", authority, authorityURIPrefix)
+		}
+	}
+	if c.RateLimit != nil && *c.RateLimit < 0 {
+		return v, fmt.Errorf("rateLimit can't be negative")
+	}
+	if c.RateLimitBurst != nil && *c.RateLimitBurst < 1 {
+		return v, fmt.Errorf("rateLimitBurst must be at least 1")
+	}
+	return v, nil
+}
+
+// ConfigStore applies the runtime config, reloading it from a file when it changes. It serves the active config,
+// along with the settings the service was started with, at the config endpoint.
+type ConfigStore struct {
+	path        string
+	defaults    RuntimeConfig
+	handler     *Handler
+	rateLimiter *RateLimiter
+	startup     map[string]interface{}
+
+	mu       sync.RWMutex
+	active   RuntimeConfig
+	loadedAt time.Time
+}
+
+// NewConfigStore applies defaults, overridden by the config file at path if there is one, to handler and rateLimiter.
+// rateLimiter may be nil. startup is shown at the config endpoint, with secrets redacted.
+func NewConfigStore(path string, defaults RuntimeConfig, handler *Handler, rateLimiter *RateLimiter, startup map[string]interface{}) (*ConfigStore, error) {
+	s := &ConfigStore{path: path, defaults: defaults, handler: handler, rateLimiter: rateLimiter, startup: redactSettings(startup)}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the config file and applies it. If the file is invalid, the active config is kept.
+func (s *ConfigStore) Reload() error {
+	config := s.defaults
+	if s.path != "" {
+		data, err := ioutil.ReadFile(s.path)
+		if err != nil {
+			configReloadFailures.Inc(1)
+			return err
+		}
+		var file RuntimeConfig
+		if err := json.Unmarshal(data, &file); err != nil {
+			configReloadFailures.Inc(1)
+			return fmt.Errorf("parsing config file %s: %v", s.path, err)
+		}
+		config = config.merge(file)
+	}
+
+	v, err := config.validate()
+	if err != nil {
+		configReloadFailures.Inc(1)
+		return err
+	}
+
+	s.handler.SetCacheControlHeader(CacheControlForDuration(v.cacheDuration))
+	log.Logger().SetLevel(v.logLevel)
+	s.handler.SetAuthorities(config.Authorities)
+	if s.rateLimiter != nil && config.RateLimit != nil && config.RateLimitBurst != nil {
+		s.rateLimiter.SetLimit(float64(*config.RateLimit), *config.RateLimitBurst)
+	}
+
+	s.mu.Lock()
+	s.active = config
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Active returns the config currently applied
+func (s *ConfigStore) Active() RuntimeConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Watch reloads the config whenever the file changes or the process receives a SIGHUP, checking every interval
+// until stopped. It does nothing if there is no config file.
+func (s *ConfigStore) Watch(interval time.Duration) (stop func()) {
+	if s.path == "" {
+		return func() {}
+	}
+	return watchFile(s.path, interval, func() {
+		if err := s.Reload(); err != nil {
+			log.WithError(err).Error("Failed to reload config, keeping the previous one")
+			return
+		}
+		log.WithField("path", s.path).Info("Reloaded config")
+	})
+}
+
+// ConfigHandler serves the active config
+func (s *ConfigStore) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	body := map[string]interface{}{
+		"configFile": s.path,
+		"loadedAt":   s.loadedAt.UTC().Format(time.RFC3339),
+		"runtime":    s.active,
+		"startup":    s.startup,
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(body)
+}
+
+// redactSettings hides secret settings, and passwords in URLs such as NEO_URL
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	redactedSettings := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		if secretSetting.MatchString(k) {
+			redactedSettings[k] = redacted
+			continue
+		}
+		if str, ok := v.(string); ok {
+			// Settings such as NEO_URL may be a comma separated list of URLs
+			urls := strings.Split(str, ",")
+			for i, s := range urls {
+				urls[i] = redactURL(s)
+			}
+			v = strings.Join(urls, ",")
+		}
+		redactedSettings[k] = v
+	}
+	return redactedSettings
+}
+
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return u.String()
+}
@@ -1,30 +1,63 @@
 package concordances
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"time"
 
 	log "github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/neo-model-utils-go/mapper"
 	"github.com/Financial-Times/neo-utils-go/neoutils"
 	"github.com/jmcvetta/neoism"
+	"github.com/rcrowley/go-metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Driver interface
 type Driver interface {
-	ReadByConceptID(ids []string) (concordances Concordances, found bool, err error)
-	ReadByAuthority(authority string, ids []string) (concordances Concordances, found bool, err error)
+	ReadByConceptID(ctx context.Context, ids []string) (concordances Concordances, found bool, err error)
+	ReadByAuthority(ctx context.Context, authority string, ids []string) (concordances Concordances, found bool, err error)
 	CheckConnectivity() error
 }
 
 // CypherDriver struct
 type CypherDriver struct {
-	conn neoutils.NeoConnection
-	env  string
+	conn               neoutils.NeoConnection
+	env                string
+	slowQueryThreshold time.Duration
+	profiler           *queryProfiler
+	retry              RetryPolicy
+	sleep              func(context.Context, time.Duration) error
 }
 
+// datastoreError is what clients are told when a query fails. It hides the Neo4j error, which may name hosts, but
+// unwraps to it so that drivers such as RoutingDriver can tell transient failures from errors in the query.
+type datastoreError struct {
+	msg string
+	err error
+}
+
+func (e *datastoreError) Error() string {
+	return e.msg
+}
+
+func (e *datastoreError) Unwrap() error {
+	return e.err
+}
+
+// CypherDriverOption configures optional CypherDriver behaviour
+type CypherDriverOption func(*CypherDriver)
+
 //NewCypherDriver instantiate driver
-func NewCypherDriver(conn neoutils.NeoConnection, env string) CypherDriver {
-	return CypherDriver{conn, env}
+func NewCypherDriver(conn neoutils.NeoConnection, env string, opts ...CypherDriverOption) CypherDriver {
+	driver := CypherDriver{conn: conn, env: env, sleep: sleepContext}
+	for _, opt := range opts {
+		opt(&driver)
+	}
+	return driver
 }
 
 // CheckConnectivity tests neo4j by running a simple cypher query
@@ -32,7 +65,12 @@ func (pcw CypherDriver) CheckConnectivity() error {
 	return neoutils.Check(pcw.conn)
 }
 
-func (pcw CypherDriver) ReadByConceptID(identifiers []string) (concordances Concordances, found bool, err error) {
+func (pcw CypherDriver) ReadByConceptID(ctx context.Context, identifiers []string) (concordances Concordances, found bool, err error) {
+	ctx, span := tracer.Start(ctx, "CypherDriver.ReadByConceptID", trace.WithAttributes(
+		attribute.Int("concordances.identifiers.count", len(identifiers)),
+	))
+	defer func() { endSpan(span, err) }()
+
 	var results []neoReadStruct
 	query := &neoism.CypherQuery{
 		Statement: `
@@ -40,37 +78,37 @@ func (pcw CypherDriver) ReadByConceptID(identifiers []string) (concordances Conc
 		WHERE p.uuid in {identifiers}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
 		MATCH (canonical)<-[:EQUIVALENT_TO]-(leafNode:Thing)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, leafNode.authority as authority, leafNode.authorityValue as authorityValue
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, leafNode.authority as authority, leafNode.authorityValue as authorityValue, 'leafNode' as branch, p.uuid as requestedUUID, p.authority as requestedAuthority
 		UNION ALL
 
 		MATCH (p:Thing)
 		WHERE p.uuid in {identifiers}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
 		WHERE exists(canonical.leiCode)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'LEI' as authority, canonical.leiCode as authorityValue
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'LEI' as authority, canonical.leiCode as authorityValue, 'canonicalLEI' as branch, p.uuid as requestedUUID, p.authority as requestedAuthority
 		UNION ALL
 
 		MATCH (p:Location)
 		WHERE p.uuid in {identifiers}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
 		WHERE exists(canonical.iso31661)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'ISO-3166-1' as authority, canonical.iso31661 as authorityValue
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'ISO-3166-1' as authority, canonical.iso31661 as authorityValue, 'canonicalISO31661' as branch, p.uuid as requestedUUID, p.authority as requestedAuthority
 		UNION ALL
 
 		MATCH (p:Thing)
 		WHERE p.uuid in {identifiers}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
 		MATCH (canonical)<-[:EQUIVALENT_TO]-(leafNode:Thing)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'UPP' as authority, leafNode.uuid as authorityValue
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, 'UPP' as authority, leafNode.uuid as authorityValue, 'leafNodeUPP' as branch, p.uuid as requestedUUID, p.authority as requestedAuthority
         `,
 		Parameters: neoism.Props{"identifiers": identifiers},
 		Result:     &results,
 	}
 
-	err = pcw.conn.CypherBatch([]*neoism.CypherQuery{query})
+	err = pcw.runQuery(ctx, "concordancesByConceptID", query)
 	if err != nil {
 		log.Errorf("Error looking up Concordances with query %s from neoism: %+v\n", query.Statement, err)
-		return Concordances{}, false, fmt.Errorf("error accessing Concordance datastore for identifier: %v", identifiers)
+		return Concordances{}, false, &datastoreError{msg: fmt.Sprintf("error accessing Concordance datastore for identifier: %v", identifiers), err: err}
 	}
 
 	if (len(results)) == 0 {
@@ -81,28 +119,36 @@ func (pcw CypherDriver) ReadByConceptID(identifiers []string) (concordances Conc
 		Concordance: []Concordance{},
 	}
 
-	return processCypherQueryToConcordances(pcw, query, results)
+	return processCypherQueryToConcordances(ctx, pcw, results)
 
 }
 
-func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []string) (concordances Concordances, found bool, err error) {
+func (pcw CypherDriver) ReadByAuthority(ctx context.Context, authority string, identifierValues []string) (concordances Concordances, found bool, err error) {
+	ctx, span := tracer.Start(ctx, "CypherDriver.ReadByAuthority", trace.WithAttributes(
+		attribute.String("concordances.authority", authority),
+		attribute.Int("concordances.identifiers.count", len(identifierValues)),
+	))
+	defer func() { endSpan(span, err) }()
+
 	var results []neoReadStruct
 
-	authorityProperty, found := AuthorityFromURI(authority)
+	authorityProperty, found := authoritiesFromContext(ctx).fromURI(authority)
 	if !found {
 		return Concordances{}, false, nil
 	}
 
 	var query *neoism.CypherQuery
+	var statementName string
 
 	if authorityProperty == "UPP" {
+		statementName = "concordancesByUPPAuthority"
 		// We need to treat the UPP authority slightly different as it's stored elsewhere.
 		query = &neoism.CypherQuery{
 			Statement: `
 		MATCH (p:Thing)
 		WHERE p.uuid IN {authorityValue}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, p.uuid as UUID, 'UPP' as authority, p.uuid as authorityValue`,
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, p.uuid as UUID, 'UPP' as authority, p.uuid as authorityValue, 'leafNodeUPP' as branch`,
 
 			Parameters: neoism.Props{
 				"authorityValue": identifierValues,
@@ -111,12 +157,13 @@ func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []str
 		}
 	} else if authorityProperty == "LEI" {
 		// We've gotta treat LEI special like as well.
+		statementName = "concordancesByLEIAuthority"
 		query = &neoism.CypherQuery{
 			Statement: `
 		MATCH (p:Concept)
 		WHERE p.leiCode IN {authorityValue}
 		AND exists(p.prefUUID)
-		RETURN DISTINCT p.prefUUID AS canonicalUUID, labels(p) AS types, p.uuid as UUID, 'LEI' as authority, p.leiCode as authorityValue`,
+		RETURN DISTINCT p.prefUUID AS canonicalUUID, labels(p) AS types, p.uuid as UUID, 'LEI' as authority, p.leiCode as authorityValue, 'canonicalLEI' as branch`,
 
 			Parameters: neoism.Props{
 				"authorityValue": identifierValues,
@@ -124,12 +171,13 @@ func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []str
 			Result: &results,
 		}
 	} else if authorityProperty == "ISO-3166-1" {
+		statementName = "concordancesByISO31661Authority"
 		query = &neoism.CypherQuery{
 			Statement: `
 		MATCH (canonical:Location)
 		WHERE canonical.iso31661 IN {authorityValue}
 		AND exists(canonical.prefUUID)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, canonical.uuid as UUID, 'ISO-3166-1' as authority, canonical.iso31661 as authorityValue
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, canonical.uuid as UUID, 'ISO-3166-1' as authority, canonical.iso31661 as authorityValue, 'canonicalISO31661' as branch
 			`,
 			Parameters: neoism.Props{
 				"authorityValue": identifierValues,
@@ -137,12 +185,13 @@ func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []str
 			Result: &results,
 		}
 	} else {
+		statementName = "concordancesByAuthority"
 		query = &neoism.CypherQuery{
 			Statement: `
 		MATCH (p:Thing)
 		WHERE p.authority = {authority} AND p.authorityValue IN {authorityValue}
 		MATCH (p)-[:EQUIVALENT_TO]->(canonical:Concept)
-		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, p.uuid as UUID, p.authority as authority, p.authorityValue as authorityValue`,
+		RETURN DISTINCT canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, p.uuid as UUID, p.authority as authority, p.authorityValue as authorityValue, 'leafNode' as branch`,
 
 			Parameters: neoism.Props{
 				"authorityValue": identifierValues,
@@ -152,10 +201,10 @@ func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []str
 		}
 	}
 
-	err = pcw.conn.CypherBatch([]*neoism.CypherQuery{query})
+	err = pcw.runQuery(ctx, statementName, query)
 	if err != nil {
 		log.Errorf("Error looking up Concordances with query %s from neoism: %+v\n", query.Statement, err)
-		return Concordances{}, false, fmt.Errorf("error accessing Concordance datastore for identifier: %v", identifierValues)
+		return Concordances{}, false, &datastoreError{msg: fmt.Sprintf("error accessing Concordance datastore for identifier: %v", identifierValues), err: err}
 	}
 
 	if (len(results)) == 0 {
@@ -166,17 +215,11 @@ func (pcw CypherDriver) ReadByAuthority(authority string, identifierValues []str
 		Concordance: []Concordance{},
 	}
 
-	return processCypherQueryToConcordances(pcw, query, results)
+	return processCypherQueryToConcordances(ctx, pcw, results)
 }
 
-func processCypherQueryToConcordances(pcw CypherDriver, q *neoism.CypherQuery, results []neoReadStruct) (concordances Concordances, found bool, err error) {
-	err = pcw.conn.CypherBatch([]*neoism.CypherQuery{q})
-	if err != nil {
-		log.Errorf("Error looking up Concordances with query %s from neoism: %+v\n", q.Statement, err)
-		return Concordances{}, false, fmt.Errorf("Error accessing Concordance datastore")
-	}
-
-	concordances = neoReadStructToConcordances(results, pcw.env)
+func processCypherQueryToConcordances(ctx context.Context, pcw CypherDriver, results []neoReadStruct) (concordances Concordances, found bool, err error) {
+	concordances = neoReadStructToConcordances(results, pcw.env, authoritiesFromContext(ctx), debugFromContext(ctx))
 
 	if (len(concordances.Concordance)) == 0 {
 		return Concordances{}, false, nil
@@ -184,29 +227,134 @@ func processCypherQueryToConcordances(pcw CypherDriver, q *neoism.CypherQuery, r
 	return concordances, true, nil
 }
 
-func neoReadStructToConcordances(neo []neoReadStruct, env string) (concordances Concordances) {
+// runQuery executes a single Cypher statement inside its own span, recording the
+// statement name, the number of parameters and the number of rows returned.
+func (pcw CypherDriver) runQuery(ctx context.Context, statementName string, query *neoism.CypherQuery) (err error) {
+	_, span := tracer.Start(ctx, "cypher."+statementName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "neo4j"),
+		attribute.String("db.statement.name", statementName),
+		attribute.Int("db.statement.parameters.count", len(query.Parameters)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	retries, err := pcw.runWithRetries(ctx, statementName, query, func() error {
+		start := time.Now()
+		err := pcw.conn.CypherBatch([]*neoism.CypherQuery{query})
+		pcw.logIfSlow(statementName, query, time.Since(start))
+		return err
+	})
+	span.SetAttributes(attribute.Int("db.retries.count", retries))
+	if err == nil {
+		span.SetAttributes(attribute.Int("db.rows.count", resultCount(query.Result)))
+	}
+	return err
+}
+
+// resultCount returns the number of rows neoism unmarshalled into a query's Result slice.
+func resultCount(result interface{}) int {
+	v := reflect.Indirect(reflect.ValueOf(result))
+	if v.Kind() != reflect.Slice {
+		return 0
+	}
+	return v.Len()
+}
+
+func neoReadStructToConcordances(neo []neoReadStruct, env string, authorities authorities, debug bool) (concordances Concordances) {
 	concordances = Concordances{
 		Concordance: []Concordance{},
 	}
+	seen := map[concordanceKey]int{}
+	seenFor := map[concordanceKey]string{}
+	requested := map[string][]string{}
+	merged := map[string][]string{}
+	duplicates := 0
 	for _, neoCon := range neo {
 		var con = Concordance{}
 		var concept = Concept{}
 
 		concept.ID = mapper.IDURL(neoCon.CanonicalUUID)
 		concept.APIURL = mapper.APIURL(neoCon.CanonicalUUID, neoCon.Types, env)
-		authorityURI, found := AuthorityToURI(neoCon.Authority)
+		authorityURI, found := authorities.toURI(neoCon.Authority)
 		if !found {
 			log.Debugf("Unsupported authority: %s", neoCon.Authority)
 			continue
 		}
 		con.Identifier = Identifier{Authority: authorityURI, IdentifierValue: neoCon.AuthorityValue}
 
+		if neoCon.RequestedUUID != "" && neoCon.RequestedUUID != neoCon.CanonicalUUID {
+			requested[concept.ID] = appendUnique(requested[concept.ID], mapper.IDURL(neoCon.RequestedUUID))
+			// A canonical concept takes its prefUUID from its Smartlogic or ManagedLocation source, so any other
+			// such source it has was once canonical itself and has since been merged into this concept
+			if canonicalAuthorities[neoCon.RequestedAuthority] {
+				merged[concept.ID] = appendUnique(merged[concept.ID], mapper.IDURL(neoCon.RequestedUUID))
+			}
+		}
+
+		// The UNION branches can overlap when data is messy, so each identifier is only returned once per concept.
+		// Rows repeated because the caller asked for several UUIDs of the same concept aren't counted as duplicates.
+		key := concordanceKey{conceptID: concept.ID, authority: authorityURI, value: neoCon.AuthorityValue}
+		if i, ok := seen[key]; ok {
+			if seenFor[key] == neoCon.RequestedUUID {
+				duplicates++
+				if debug {
+					dup := &concordances.Concordance[i].Identifier
+					dup.Branches = append(dup.Branches, neoCon.Branch)
+				}
+			}
+			continue
+		}
+		seen[key] = len(concordances.Concordance)
+		seenFor[key] = neoCon.RequestedUUID
+
+		if debug {
+			con.Identifier.Branches = []string{neoCon.Branch}
+		}
 		con.Concept = concept
 		concordances.Concordance = append(concordances.Concordance, con)
 	}
+	if duplicates > 0 {
+		suppressedDuplicates.Inc(int64(duplicates))
+		log.Debugf("Suppressed %d duplicate identifiers", duplicates)
+	}
+	// Rows come back in no particular order, so the IDs are sorted to keep responses, and their ETags, the same
+	for _, ids := range requested {
+		sort.Strings(ids)
+	}
+	for _, ids := range merged {
+		sort.Strings(ids)
+	}
+	for i := range concordances.Concordance {
+		concordances.Concordance[i].Concept.RequestedIDs = requested[concordances.Concordance[i].Concept.ID]
+		concordances.Concordance[i].Concept.MergedFrom = merged[concordances.Concordance[i].Concept.ID]
+	}
+	SortConcordances(concordances.Concordance, DefaultSortOrder)
 	return concordances
 }
 
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+type concordanceKey struct {
+	conceptID string
+	authority string
+	value     string
+}
+
+// suppressedDuplicates counts identifiers returned by more than one query branch for the same concept
+var suppressedDuplicates = metrics.GetOrRegisterCounter("concordances.duplicate_identifiers_suppressed", metrics.DefaultRegistry)
+
+// canonicalAuthorities are the authorities whose source UUIDs are used as canonical prefUUIDs
+var canonicalAuthorities = map[string]bool{
+	"Smartlogic":      true,
+	"ManagedLocation": true,
+}
+
 // Map of authority to URI for the supported concordance IDs
 var authorityMap = map[string]string{
 	"TME":             "http://api.ft.com/system/FT-TME",
@@ -221,16 +369,58 @@ var authorityMap = map[string]string{
 	"DBPedia":         "http://api.ft.com/system/DBPEDIA",
 }
 
-func AuthorityFromURI(uri string) (string, bool) {
-	for a, u := range authorityMap {
+// authorities maps the authorities identifiers are concorded from, as named in Neo4j, to their URIs. Each Handler has
+// its own, which it passes to its driver in the context of every request.
+type authorities map[string]string
+
+// withExtraAuthorities returns authorityMap with extra added to it, or overriding its URIs
+func withExtraAuthorities(extra map[string]string) authorities {
+	a := make(authorities, len(authorityMap)+len(extra))
+	for authority, uri := range authorityMap {
+		a[authority] = uri
+	}
+	for authority, uri := range extra {
+		a[authority] = uri
+	}
+	return a
+}
+
+func (a authorities) fromURI(uri string) (string, bool) {
+	for authority, u := range a {
 		if u == uri {
-			return a, true
+			return authority, true
 		}
 	}
 	return "", false
 }
 
+func (a authorities) toURI(authority string) (string, bool) {
+	uri, found := a[authority]
+	return uri, found
+}
+
+type authoritiesKey struct{}
+
+// withAuthorities sets the authorities the drivers answering a request concord identifiers from
+func withAuthorities(ctx context.Context, a authorities) context.Context {
+	return context.WithValue(ctx, authoritiesKey{}, a)
+}
+
+// authoritiesFromContext returns the authorities of the Handler serving a request, or the built in ones
+func authoritiesFromContext(ctx context.Context) authorities {
+	if a, ok := ctx.Value(authoritiesKey{}).(authorities); ok {
+		return a
+	}
+	return authorityMap
+}
+
+// AuthorityFromURI looks an authority up by its URI among the built in ones. A Handler may have more, see
+// WithAuthorities.
+func AuthorityFromURI(uri string) (string, bool) {
+	return authorities(authorityMap).fromURI(uri)
+}
+
+// AuthorityToURI returns the URI of one of the built in authorities
 func AuthorityToURI(authority string) (string, bool) {
-	authorityURI, found := authorityMap[authority]
-	return authorityURI, found
+	return authorities(authorityMap).toURI(authority)
 }
@@ -1,6 +1,7 @@
 package concordances
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -273,7 +274,7 @@ func TestNeoReadByConceptID_NewModel_Unconcorded(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByConceptID([]string{"ad56856a-7d38-48e2-a131-7d104f17e8f6"})
+	conc, found, err := undertest.ReadByConceptID(context.Background(), []string{"ad56856a-7d38-48e2-a131-7d104f17e8f6"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(2, len(conc.Concordance))
@@ -291,7 +292,7 @@ func TestNeoReadByConceptID_NewModel_Concorded(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByConceptID([]string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
+	conc, found, err := undertest.ReadByConceptID(context.Background(), []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(4, len(conc.Concordance))
@@ -309,7 +310,7 @@ func TestNeoReadByAuthority_NewModel_Unconcorded(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByAuthority("http://api.ft.com/system/FT-TME", []string{"UGFydHkgcGVvcGxl-QnJhbmRz"})
+	conc, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/FT-TME", []string{"UGFydHkgcGVvcGxl-QnJhbmRz"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(1, len(conc.Concordance))
@@ -328,7 +329,7 @@ func TestNeoReadByAuthority_NewModel_Concorded(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByAuthority("http://api.ft.com/system/SMARTLOGIC", []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
+	conc, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/SMARTLOGIC", []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(1, len(conc.Concordance))
@@ -346,7 +347,7 @@ func TestNeoReadByConceptId_ManagedLocation(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByConceptID([]string{"5aba454b-3e31-31b9-bdeb-0caf83f62b44"})
+	conc, found, err := undertest.ReadByConceptID(context.Background(), []string{"5aba454b-3e31-31b9-bdeb-0caf83f62b44"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(7, len(conc.Concordance))
@@ -364,7 +365,7 @@ func TestNeoReadByAuthority_ManagedLocation(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByAuthority("http://api.ft.com/system/MANAGEDLOCATION", []string{"5aba454b-3e31-31b9-bdeb-0caf83f62b44"})
+	conc, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/MANAGEDLOCATION", []string{"5aba454b-3e31-31b9-bdeb-0caf83f62b44"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(1, len(conc.Concordance))
@@ -382,7 +383,7 @@ func TestNeoReadByAuthority_ISO31661(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	conc, found, err := undertest.ReadByAuthority("http://api.ft.com/system/ISO-3166-1", []string{"RO"})
+	conc, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/ISO-3166-1", []string{"RO"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.Equal(1, len(conc.Concordance))
@@ -401,7 +402,7 @@ func TestNeoReadByConceptIDToConcordancesMandatoryFields(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByConceptID([]string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
+	cs, found, err := undertest.ReadByConceptID(context.Background(), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -421,7 +422,7 @@ func TestNeoReadByAuthorityToConcordancesMandatoryFields(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByAuthority("http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
+	cs, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -441,7 +442,7 @@ func TestNeoReadByAuthorityToConcordancesByUPPAuthority(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByAuthority("http://api.ft.com/system/UPP", []string{"d56e7388-25cb-343e-aea9-8b512e28476e"})
+	cs, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/UPP", []string{"d56e7388-25cb-343e-aea9-8b512e28476e"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -461,7 +462,7 @@ func TestNeoReadByAuthorityToConcordancesByLEIAuthority(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByAuthority("http://api.ft.com/system/LEI", []string{"VNF516RB4DFV5NQ22UF0"})
+	cs, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/LEI", []string{"VNF516RB4DFV5NQ22UF0"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -481,7 +482,7 @@ func TestNeoReadByAuthorityOnlyOneConcordancePerIdentifierValue(t *testing.T) {
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByAuthority("http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
+	cs, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -503,7 +504,7 @@ func TestNeoReadByConceptIdReturnMultipleConcordancesForMultipleIdentifiers(t *t
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByConceptID([]string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
+	cs, found, err := undertest.ReadByConceptID(context.Background(), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
 	assert.NoError(err)
 	assert.True(found)
 	assert.NotEmpty(cs.Concordance)
@@ -524,12 +525,34 @@ func TestNeoReadByAuthorityEmptyConcordancesWhenUnsupportedAuthority(t *testing.
 	defer cleanUp(assert, db)
 
 	undertest := NewCypherDriver(db, "prod")
-	cs, found, err := undertest.ReadByAuthority("http://api.ft.com/system/UnsupportedAuthority", []string{"DANMUR-1"})
+	cs, found, err := undertest.ReadByAuthority(context.Background(), "http://api.ft.com/system/UnsupportedAuthority", []string{"DANMUR-1"})
 	assert.NoError(err)
 	assert.False(found)
 	assert.Empty(cs.Concordance)
 }
 
+func TestNeoReadByConceptID_MergedConcept(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnection(t, assert)
+	conceptRW := concepts.NewConceptService(db)
+	assert.NoError(conceptRW.Initialise())
+
+	writeGenericConceptJSONToService(conceptRW, "./fixtures/Brand-Merged-b20801ac-5a76-43cf-b816-8c3b2f7133ad.json", assert)
+	defer cleanUp(assert, db)
+
+	undertest := NewCypherDriver(db, "prod")
+	conc, found, err := undertest.ReadByConceptID(context.Background(), []string{"9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(4, len(conc.Concordance))
+
+	for _, c := range conc.Concordance {
+		assert.Equal("http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad", c.Concept.ID)
+		assert.Equal([]string{"http://api.ft.com/things/9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"}, c.Concept.RequestedIDs)
+		assert.Equal([]string{"http://api.ft.com/things/9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"}, c.Concept.MergedFrom)
+	}
+}
+
 func readConceptAndCompare(t *testing.T, expected Concordances, actual Concordances, testName string) {
 
 	sortConcordances(expected.Concordance)
@@ -592,6 +615,8 @@ func cleanUp(assert *assert.Assertions, db neoutils.NeoConnection) {
 			Statement: fmt.Sprintf("MATCH (t:Thing {uuid: '%v'})--(i:Identifier) OPTIONAL MATCH (t)-[:EQUIVALENT_TO]-(e:Thing) DETACH DELETE t, i", "dbb0bdae-1f0c-11e4-b0cb-b2227cce2b54"),
 		}, {
 			Statement: fmt.Sprintf("MATCH (t:Thing {prefUUID: '%v'}) DETACH DELETE t", "ad56856a-7d38-48e2-a131-7d104f17e8f6"),
+		}, {
+			Statement: fmt.Sprintf("MATCH (t:Thing {uuid: '%v'}) OPTIONAL MATCH (t)-[:IDENTIFIES]-(i:Identifier) DETACH DELETE t, i", "9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"),
 		},
 	}
 
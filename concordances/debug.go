@@ -0,0 +1,15 @@
+package concordances
+
+import "context"
+
+type debugKey struct{}
+
+// withDebug marks a request as wanting debug output in its response
+func withDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+func debugFromContext(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
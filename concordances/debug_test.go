@@ -0,0 +1,95 @@
+package concordances
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var overlappingBranchRows = []neoReadStruct{
+	{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "UPP", AuthorityValue: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Branch: "leafNode"},
+	{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "FACTSET", AuthorityValue: "7IV872-E", Branch: "leafNode"},
+	{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "UPP", AuthorityValue: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Branch: "leafNodeUPP"},
+}
+
+func TestDuplicateIdentifiersAcrossBranchesAreSuppressed(t *testing.T) {
+	assert := assert.New(t)
+	before := suppressedDuplicates.Count()
+
+	actual := neoReadStructToConcordances(overlappingBranchRows, "prod", authorityMap, false)
+
+	assert.Equal([]Concordance{
+		{
+			Concept:    Concept{ID: "http://api.ft.com/things/cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", APIURL: "http://api.ft.com/organisations/cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+			Identifier: Identifier{Authority: "http://api.ft.com/system/FACTSET", IdentifierValue: "7IV872-E"},
+		},
+		{
+			Concept:    Concept{ID: "http://api.ft.com/things/cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", APIURL: "http://api.ft.com/organisations/cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+			Identifier: Identifier{Authority: "http://api.ft.com/system/UPP", IdentifierValue: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+		},
+	}, actual.Concordance)
+	assert.Equal(int64(1), suppressedDuplicates.Count()-before)
+}
+
+func TestDebugReportsEveryBranchProducingAnIdentifier(t *testing.T) {
+	assert := assert.New(t)
+	driver := NewCypherDriver(&fakeNeoConnection{rows: overlappingBranchRows}, "prod")
+
+	actual, found, err := driver.ReadByConceptID(withDebug(context.Background()), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
+	assert.NoError(err)
+	assert.True(found)
+	if assert.Len(actual.Concordance, 2) {
+		assert.Equal([]string{"leafNode"}, actual.Concordance[0].Identifier.Branches)
+		assert.Equal([]string{"leafNode", "leafNodeUPP"}, actual.Concordance[1].Identifier.Branches)
+	}
+
+	actual, _, err = driver.ReadByConceptID(context.Background(), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
+	assert.NoError(err)
+	for _, c := range actual.Concordance {
+		assert.Nil(c.Identifier.Branches)
+	}
+}
+
+func TestRequestedLeafUUIDIsReportedAgainstCanonicalConcept(t *testing.T) {
+	assert := assert.New(t)
+	before := suppressedDuplicates.Count()
+	rows := []neoReadStruct{
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", Branch: "leafNode", RequestedUUID: "70f4732b-7f7d-30a1-9c29-0cceec23760e"},
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", Branch: "leafNode", RequestedUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+	}
+
+	actual := neoReadStructToConcordances(rows, "prod", authorityMap, false)
+
+	if assert.Len(actual.Concordance, 1) {
+		assert.Equal("http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad", actual.Concordance[0].Concept.ID)
+		assert.Equal([]string{"http://api.ft.com/things/70f4732b-7f7d-30a1-9c29-0cceec23760e"}, actual.Concordance[0].Concept.RequestedIDs)
+	}
+	assert.Equal(int64(0), suppressedDuplicates.Count()-before, "asking for two UUIDs of one concept isn't a duplicate in the data")
+}
+
+func TestRequestedAndMergedIDsDoNotDependOnTheOrderOfRows(t *testing.T) {
+	rows := []neoReadStruct{
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", RequestedUUID: "9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c", RequestedAuthority: "Smartlogic"},
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", RequestedUUID: "70f4732b-7f7d-30a1-9c29-0cceec23760e", RequestedAuthority: "Smartlogic"},
+	}
+	reversed := []neoReadStruct{rows[1], rows[0]}
+
+	assert.Equal(t, neoReadStructToConcordances(rows, "prod", authorityMap, false), neoReadStructToConcordances(reversed, "prod", authorityMap, false))
+}
+
+func TestRequestedSmartlogicSourceOfAnotherConceptIsReportedAsMerged(t *testing.T) {
+	assert := assert.New(t)
+	rows := []neoReadStruct{
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", RequestedUUID: "9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c", RequestedAuthority: "Smartlogic"},
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz", RequestedUUID: "70f4732b-7f7d-30a1-9c29-0cceec23760e", RequestedAuthority: "TME"},
+	}
+
+	actual := neoReadStructToConcordances(rows, "prod", authorityMap, false)
+
+	if assert.Len(actual.Concordance, 1) {
+		concept := actual.Concordance[0].Concept
+		assert.Equal([]string{"http://api.ft.com/things/70f4732b-7f7d-30a1-9c29-0cceec23760e", "http://api.ft.com/things/9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"}, concept.RequestedIDs)
+		assert.Equal([]string{"http://api.ft.com/things/9a4e4f8d-8bd3-4b45-9e38-3d0e7f0e4a1c"}, concept.MergedFrom)
+	}
+}
@@ -0,0 +1,348 @@
+package concordances
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/rcrowley/go-metrics"
+)
+
+// staleHeader marks responses built from the fallback driver's results
+const staleHeader = "X-Concordances-Stale"
+
+var (
+	fallbackReads = metrics.GetOrRegisterCounter("concordances.fallback.served", metrics.DefaultRegistry)
+	breakerOpen   = metrics.GetOrRegisterGauge("concordances.fallback.breaker_open", metrics.DefaultRegistry)
+)
+
+var errNotCached = errors.New("lookup is not in the stale cache")
+
+// FallbackChecker is implemented by drivers which can serve stale results when their primary store is unavailable
+type FallbackChecker interface {
+	// CheckFallback returns the reason results are being served from the fallback, or nil if they aren't
+	CheckFallback() error
+	// CheckSecondary returns an error if the fallback can't answer queries either
+	CheckSecondary() error
+}
+
+type fallbackKey struct{}
+
+// withFallbackMarker lets a FallbackDriver record that a request was answered from its fallback
+func withFallbackMarker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fallbackKey{}, new(int32))
+}
+
+func markServedFromFallback(ctx context.Context) {
+	if marker, ok := ctx.Value(fallbackKey{}).(*int32); ok {
+		atomic.StoreInt32(marker, 1)
+	}
+}
+
+func servedFromFallback(ctx context.Context) bool {
+	marker, ok := ctx.Value(fallbackKey{}).(*int32)
+	return ok && atomic.LoadInt32(marker) == 1
+}
+
+// FallbackOption configures a FallbackDriver
+type FallbackOption func(*FallbackDriver)
+
+// WithBreakerThreshold opens the circuit breaker after n consecutive failures of the primary driver. The default is 5.
+func WithBreakerThreshold(n int) FallbackOption {
+	return func(d *FallbackDriver) {
+		d.threshold = n
+	}
+}
+
+// WithBreakerOpenDuration is how long the primary driver is left alone once the breaker opens, before a single
+// query is let through to check whether it has recovered. The default is 30s.
+func WithBreakerOpenDuration(openFor time.Duration) FallbackOption {
+	return func(d *FallbackDriver) {
+		d.openFor = openFor
+	}
+}
+
+// WithBreakerClock replaces time.Now, which is used to decide when to retry the primary driver
+func WithBreakerClock(now func() time.Time) FallbackOption {
+	return func(d *FallbackDriver) {
+		d.now = now
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpened
+	// breakerHalfOpen lets a single trial query through to the primary driver
+	breakerHalfOpen
+)
+
+// FallbackDriver reads from a primary Driver, answering from a secondary one, such as a read replica or a
+// StaleCache, when the primary fails. After a run of failures a circuit breaker stops sending queries to the primary
+// at all, so an outage doesn't cost every request a timeout.
+//
+// Queries shed with ErrOverloaded and those cancelled by the caller say nothing about the primary's health, so they
+// are returned as they are.
+type FallbackDriver struct {
+	primary   Driver
+	secondary Driver
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	lastErr  error
+}
+
+// NewFallbackDriver answers from secondary whenever primary fails or its circuit breaker is open
+func NewFallbackDriver(primary Driver, secondary Driver, opts ...FallbackOption) *FallbackDriver {
+	d := &FallbackDriver{primary: primary, secondary: secondary, threshold: 5, openFor: 30 * time.Second, now: time.Now}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// ReadByConceptID reads from the primary driver, falling back to the secondary
+func (d *FallbackDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	return d.read(ctx, func(driver Driver) (Concordances, bool, error) {
+		return driver.ReadByConceptID(ctx, ids)
+	}, func(c Concordances, found bool) {
+		if cache, ok := d.secondary.(*StaleCache); ok {
+			cache.add(conceptCacheKey(ids), c, found)
+		}
+	})
+}
+
+// ReadByAuthority reads from the primary driver, falling back to the secondary
+func (d *FallbackDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	return d.read(ctx, func(driver Driver) (Concordances, bool, error) {
+		return driver.ReadByAuthority(ctx, authority, ids)
+	}, func(c Concordances, found bool) {
+		if cache, ok := d.secondary.(*StaleCache); ok {
+			cache.add(authorityCacheKey(authority, ids), c, found)
+		}
+	})
+}
+
+func (d *FallbackDriver) read(ctx context.Context, query func(Driver) (Concordances, bool, error), succeeded func(Concordances, bool)) (Concordances, bool, error) {
+	if !d.allow() {
+		return d.fallback(ctx, query, d.lastError())
+	}
+
+	c, found, err := query(d.primary)
+	switch {
+	case err == nil:
+		d.success()
+		succeeded(c, found)
+		return c, found, nil
+	case errors.Is(err, ErrOverloaded) || ctx.Err() != nil:
+		d.abandon()
+		return c, found, err
+	default:
+		d.failure(err)
+		return d.fallback(ctx, query, err)
+	}
+}
+
+// fallback answers from the secondary driver, or returns the primary's error if the secondary can't answer either
+func (d *FallbackDriver) fallback(ctx context.Context, query func(Driver) (Concordances, bool, error), primaryErr error) (Concordances, bool, error) {
+	c, found, err := query(d.secondary)
+	if err != nil {
+		if !errors.Is(err, errNotCached) {
+			log.WithError(err).Warn("Fallback concordance driver failed")
+		}
+		return Concordances{}, false, primaryErr
+	}
+	fallbackReads.Inc(1)
+	markServedFromFallback(ctx)
+	return c, found, nil
+}
+
+// allow reports whether a query should be sent to the primary driver
+func (d *FallbackDriver) allow() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch d.state {
+	case breakerOpened:
+		if d.now().Sub(d.openedAt) < d.openFor {
+			return false
+		}
+		d.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+func (d *FallbackDriver) success() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state != breakerClosed {
+		log.Info("Primary concordance driver has recovered, closing circuit breaker")
+		breakerOpen.Update(0)
+	}
+	d.state = breakerClosed
+	d.failures = 0
+	d.lastErr = nil
+}
+
+func (d *FallbackDriver) failure(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures++
+	d.lastErr = err
+	if d.state == breakerHalfOpen || (d.state == breakerClosed && d.failures >= d.threshold) {
+		if d.state == breakerClosed {
+			log.WithError(err).Warn("Primary concordance driver is failing, opening circuit breaker")
+		}
+		d.state = breakerOpened
+		d.openedAt = d.now()
+		breakerOpen.Update(1)
+	}
+}
+
+// abandon gives up a trial query which neither succeeded nor failed, so another can be tried
+func (d *FallbackDriver) abandon() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == breakerHalfOpen {
+		d.state = breakerOpened
+	}
+}
+
+func (d *FallbackDriver) lastError() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastErr
+}
+
+// Unwrap returns the primary driver
+func (d *FallbackDriver) Unwrap() Driver {
+	return d.primary
+}
+
+// CheckConnectivity checks the primary driver, so an outage is reported even while the secondary answers queries
+func (d *FallbackDriver) CheckConnectivity() error {
+	return d.primary.CheckConnectivity()
+}
+
+// CheckSecondary checks the driver which answers queries while the primary fails
+func (d *FallbackDriver) CheckSecondary() error {
+	return d.secondary.CheckConnectivity()
+}
+
+// CheckFallback returns the primary driver's last error while its circuit breaker is open
+func (d *FallbackDriver) CheckFallback() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == breakerClosed {
+		return nil
+	}
+	return d.lastErr
+}
+
+// CheckIndexes delegates to the primary driver, if it can check indexes
+func (d *FallbackDriver) CheckIndexes() error {
+	if checker, ok := d.primary.(IndexChecker); ok {
+		return checker.CheckIndexes()
+	}
+	return nil
+}
+
+// StaleCache is a fallback Driver which answers with the primary driver's most recent results for the same lookup.
+// It is filled by the FallbackDriver it is the secondary of, and holds up to maxEntries lookups, evicting the
+// least recently used.
+type StaleCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type staleCacheEntry struct {
+	key          string
+	concordances Concordances
+	found        bool
+}
+
+// NewStaleCache returns an empty cache of up to maxEntries lookups
+func NewStaleCache(maxEntries int) *StaleCache {
+	return &StaleCache{maxEntries: maxEntries, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// ReadByConceptID answers with the cached results of the same lookup
+func (c *StaleCache) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	return c.get(conceptCacheKey(ids))
+}
+
+// ReadByAuthority answers with the cached results of the same lookup
+func (c *StaleCache) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	return c.get(authorityCacheKey(authority, ids))
+}
+
+// CheckConnectivity always succeeds, although the cache may not hold every lookup
+func (c *StaleCache) CheckConnectivity() error {
+	return nil
+}
+
+func (c *StaleCache) get(key string) (Concordances, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return Concordances{}, false, errNotCached
+	}
+	c.lru.MoveToFront(e)
+	entry := e.Value.(*staleCacheEntry)
+	// Handlers sort results in place, so each caller gets a copy
+	return Concordances{Concordance: append([]Concordance(nil), entry.concordances.Concordance...)}, entry.found, nil
+}
+
+func (c *StaleCache) add(key string, concordances Concordances, found bool) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	concordances = Concordances{Concordance: append([]Concordance(nil), concordances.Concordance...)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value = &staleCacheEntry{key: key, concordances: concordances, found: found}
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&staleCacheEntry{key: key, concordances: concordances, found: found})
+	if c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*staleCacheEntry).key)
+	}
+}
+
+func conceptCacheKey(ids []string) string {
+	return "concept\x00" + sortedKey(ids)
+}
+
+func authorityCacheKey(authority string, ids []string) string {
+	return "authority\x00" + authority + "\x00" + sortedKey(ids)
+}
+
+// sortedKey makes lookups for the same IDs in a different order share a cache entry
+func sortedKey(ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
@@ -0,0 +1,247 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNeoDown = errors.New("neo4j is down")
+
+// flakyDriver answers like a fixedDriver until it is given an error to fail with
+type flakyDriver struct {
+	fixedDriver
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (d *flakyDriver) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *flakyDriver) callCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+func (d *flakyDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	d.mu.Lock()
+	d.calls++
+	err := d.err
+	d.mu.Unlock()
+	if err != nil {
+		return Concordances{}, false, err
+	}
+	return d.fixedDriver.ReadByConceptID(ctx, ids)
+}
+
+func (d *flakyDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	return d.ReadByConceptID(ctx, ids)
+}
+
+func (d *flakyDriver) CheckConnectivity() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+var (
+	primaryResult   = Concordances{[]Concordance{concordedBrandSmartlogic}}
+	secondaryResult = Concordances{[]Concordance{concordedBrandTME}}
+)
+
+func TestFallbackDriverAnswersFromSecondaryWhenPrimaryFails(t *testing.T) {
+	assert := assert.New(t)
+	primary := &flakyDriver{fixedDriver: fixedDriver{result: primaryResult}}
+	driver := NewFallbackDriver(primary, fixedDriver{result: secondaryResult})
+
+	ctx := withFallbackMarker(context.Background())
+	c, found, err := driver.ReadByConceptID(ctx, []string{"a"})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(primaryResult, c)
+	assert.False(servedFromFallback(ctx))
+
+	primary.fail(errNeoDown)
+	ctx = withFallbackMarker(context.Background())
+	c, _, err = driver.ReadByAuthority(ctx, "http://api.ft.com/system/FT-TME", []string{"a"})
+	assert.NoError(err)
+	assert.Equal(secondaryResult, c)
+	assert.True(servedFromFallback(ctx))
+}
+
+func TestFallbackDriverReturnsPrimaryErrorIfSecondaryFails(t *testing.T) {
+	primary := &flakyDriver{}
+	primary.fail(errNeoDown)
+	secondary := &flakyDriver{}
+	secondary.fail(errors.New("replica is down too"))
+
+	_, _, err := NewFallbackDriver(primary, secondary).ReadByConceptID(context.Background(), []string{"a"})
+	assert.Equal(t, errNeoDown, err)
+}
+
+func TestFallbackDriverCircuitBreakerOpensAndRecovers(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary := &flakyDriver{fixedDriver: fixedDriver{result: primaryResult}}
+	primary.fail(errNeoDown)
+	driver := NewFallbackDriver(primary, fixedDriver{result: secondaryResult},
+		WithBreakerThreshold(2), WithBreakerOpenDuration(time.Minute), WithBreakerClock(func() time.Time { return now }))
+
+	for i := 0; i < 2; i++ {
+		_, _, err := driver.ReadByConceptID(context.Background(), []string{"a"})
+		assert.NoError(err)
+	}
+	assert.Equal(2, primary.callCount())
+	assert.Equal(errNeoDown, driver.CheckFallback())
+
+	c, _, err := driver.ReadByConceptID(context.Background(), []string{"a"})
+	assert.NoError(err)
+	assert.Equal(secondaryResult, c)
+	assert.Equal(2, primary.callCount(), "the primary isn't queried while the breaker is open")
+
+	now = now.Add(time.Minute)
+	driver.ReadByConceptID(context.Background(), []string{"a"})
+	assert.Equal(3, primary.callCount(), "a trial query is let through once the breaker has been open for long enough")
+	driver.ReadByConceptID(context.Background(), []string{"a"})
+	assert.Equal(3, primary.callCount(), "a failed trial reopens the breaker")
+
+	now = now.Add(time.Minute)
+	primary.fail(nil)
+	c, _, err = driver.ReadByConceptID(context.Background(), []string{"a"})
+	assert.NoError(err)
+	assert.Equal(primaryResult, c)
+	assert.NoError(driver.CheckFallback())
+}
+
+func TestFallbackDriverPassesThroughShedAndCancelledQueries(t *testing.T) {
+	assert := assert.New(t)
+	primary := &flakyDriver{}
+	primary.fail(ErrOverloaded)
+	driver := NewFallbackDriver(primary, fixedDriver{result: secondaryResult}, WithBreakerThreshold(1))
+
+	_, _, err := driver.ReadByConceptID(context.Background(), []string{"a"})
+	assert.Equal(ErrOverloaded, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary.fail(context.Canceled)
+	_, _, err = driver.ReadByConceptID(ctx, []string{"a"})
+	assert.Equal(context.Canceled, err)
+	assert.NoError(driver.CheckFallback(), "the breaker stays closed")
+}
+
+func TestFallbackDriverConnectivityCheckReportsThePrimary(t *testing.T) {
+	primary := &flakyDriver{}
+	primary.fail(errNeoDown)
+	driver := NewFallbackDriver(primary, NewStaleCache(10))
+	assert.Equal(t, errNeoDown, driver.CheckConnectivity())
+	assert.NoError(t, driver.CheckSecondary())
+
+	secondary := &flakyDriver{}
+	secondary.fail(errors.New("replica is down too"))
+	assert.Error(t, NewFallbackDriver(primary, secondary).CheckSecondary())
+}
+
+func TestGTGStaysGoodWhileTheFallbackCanAnswer(t *testing.T) {
+	primary := &flakyDriver{}
+	primary.fail(errNeoDown)
+	h := NewHandler(NewFallbackDriver(primary, NewStaleCache(10)))
+	h.setConnCheck(primary.CheckConnectivity())
+
+	_, err := h.Checker()
+	assert.Equal(t, errNeoDown, err, "the connectivity check reports the outage")
+	assert.True(t, h.GTG().GoodToGo)
+
+	secondary := &flakyDriver{}
+	secondary.fail(errors.New("replica is down too"))
+	h = NewHandler(NewFallbackDriver(primary, secondary))
+	h.setConnCheck(primary.CheckConnectivity())
+	assert.False(t, h.GTG().GoodToGo)
+}
+
+func TestStaleCacheAnswersWithThePrimarysLastResults(t *testing.T) {
+	assert := assert.New(t)
+	primary := &flakyDriver{fixedDriver: fixedDriver{result: primaryResult}}
+	driver := NewFallbackDriver(primary, NewStaleCache(10))
+
+	_, _, err := driver.ReadByConceptID(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	primary.fail(errNeoDown)
+
+	ctx := withFallbackMarker(context.Background())
+	c, found, err := driver.ReadByConceptID(ctx, []string{"b", "a"})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(primaryResult, c)
+	assert.True(servedFromFallback(ctx))
+
+	_, _, err = driver.ReadByConceptID(context.Background(), []string{"c"})
+	assert.Equal(errNeoDown, err, "lookups which aren't cached fail with the primary's error")
+	_, _, err = driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FT-TME", []string{"a", "b"})
+	assert.Equal(errNeoDown, err)
+}
+
+func TestStaleCacheEvictsTheLeastRecentlyUsedLookup(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewStaleCache(2)
+	cache.add(conceptCacheKey([]string{"a"}), primaryResult, true)
+	cache.add(conceptCacheKey([]string{"b"}), primaryResult, true)
+	_, _, err := cache.ReadByConceptID(context.Background(), []string{"a"})
+	assert.NoError(err)
+	cache.add(conceptCacheKey([]string{"c"}), primaryResult, true)
+
+	_, _, err = cache.ReadByConceptID(context.Background(), []string{"b"})
+	assert.Equal(errNotCached, err)
+	_, _, err = cache.ReadByConceptID(context.Background(), []string{"a"})
+	assert.NoError(err)
+	_, _, err = cache.ReadByConceptID(context.Background(), []string{"c"})
+	assert.NoError(err)
+}
+
+func TestFallbackResponsesAreMarkedStale(t *testing.T) {
+	assert := assert.New(t)
+	primary := &flakyDriver{fixedDriver: fixedDriver{result: primaryResult}}
+	h := NewHandler(NewFallbackDriver(primary, fixedDriver{result: secondaryResult}, WithBreakerThreshold(1)), WithCacheControl(testCacheControl))
+	r := mux.NewRouter()
+	h.RegisterRoutes(r)
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	res, err := http.Get(s.URL + "/concordances?conceptId=a")
+	require.NoError(t, err)
+	assert.Empty(res.Header.Get(staleHeader))
+	assert.Equal(testCacheControl, res.Header.Get("Cache-Control"))
+	_, err = h.FallbackChecker()
+	assert.NoError(err)
+
+	primary.fail(errNeoDown)
+	for _, path := range []string{
+		"/concordances?conceptId=a",
+		"/concepts/b20801ac-5a76-43cf-b816-8c3b2f7133ad/concordances",
+		"/authorities/FT-TME/identifiers/a",
+	} {
+		res, err = http.Get(s.URL + path)
+		require.NoError(t, err)
+		assert.Equal(http.StatusOK, res.StatusCode, path)
+		assert.Equal("true", res.Header.Get(staleHeader), path)
+		assert.Equal("no-cache", res.Header.Get("Cache-Control"), path)
+	}
+
+	_, err = h.FallbackChecker()
+	assert.Equal(errNeoDown, err)
+	assert.Len(h.HealthCheck().Checks, 3)
+}
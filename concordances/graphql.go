@@ -0,0 +1,263 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+const graphQLSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# A single concept with all of its identifiers
+	concept(id: ID!): ConceptIdentifiers
+	# Concordances for one or more concept IDs, which may be UUIDs or http://api.ft.com/things/ URIs
+	concordances(conceptIds: [ID!]!): [Concordance!]!
+	# Concordances for identifier values in a single authority, e.g. http://api.ft.com/system/FT-TME
+	lookup(authority: String!, values: [String!]!): [Concordance!]!
+}
+
+type Concordance {
+	concept: Concept!
+	identifier: Identifier!
+}
+
+type Concept {
+	id: ID!
+	apiUrl: String!
+	requestedIds: [ID!]!
+	mergedFrom: [ID!]!
+}
+
+type ConceptIdentifiers {
+	id: ID!
+	apiUrl: String!
+	requestedIds: [ID!]!
+	mergedFrom: [ID!]!
+	identifiers: [Identifier!]!
+}
+
+type Identifier {
+	authority: String!
+	identifierValue: String!
+}
+`
+
+const (
+	// graphQLMaxDepth stops deeply nested queries; the schema itself is only three levels deep
+	graphQLMaxDepth = 5
+	// graphQLMaxCost caps the total number of IDs and values looked up across every field in one query
+	graphQLMaxCost = 100
+	// graphQLMaxBodyBytes bounds the size of the query document and its variables
+	graphQLMaxBodyBytes = 64 * 1024
+)
+
+var errGraphQLTooComplex = fmt.Errorf("query looks up more than %d IDs in total", graphQLMaxCost)
+
+// graphQLHandler serves the concordance GraphQL schema
+func (h *Handler) graphQLHandler() http.Handler {
+	schema := graphql.MustParseSchema(graphQLSchema, &graphQLResolver{h}, graphql.MaxDepth(graphQLMaxDepth))
+	relayHandler := &relay.Handler{Schema: schema}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span, reqLog := h.startRequest(w, r, "GraphQL")
+		defer span.End()
+		reqLog.set("lookup_mode", "graphql")
+
+		r.Body = http.MaxBytesReader(w, r.Body, graphQLMaxBodyBytes)
+		relayHandler.ServeHTTP(staleHeaderWriter{w, ctx}, r.WithContext(withQueryCost(ctx)))
+		// GraphQL reports field errors in the response body, so the request itself always succeeds
+		reqLog.finish(http.StatusOK, true, nil)
+	})
+}
+
+// staleHeaderWriter marks the response once the resolvers have run, if any of them used a fallback driver's results
+type staleHeaderWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (w staleHeaderWriter) Write(b []byte) (int, error) {
+	setStaleHeader(w.ctx, w.ResponseWriter)
+	return w.ResponseWriter.Write(b)
+}
+
+type costKey struct{}
+
+func withQueryCost(ctx context.Context) context.Context {
+	return context.WithValue(ctx, costKey{}, new(int64))
+}
+
+// spend charges n lookups against the query's budget
+func spend(ctx context.Context, n int) error {
+	cost, ok := ctx.Value(costKey{}).(*int64)
+	if !ok {
+		return nil
+	}
+	if atomic.AddInt64(cost, int64(n)) > graphQLMaxCost {
+		return errGraphQLTooComplex
+	}
+	return nil
+}
+
+type graphQLResolver struct {
+	h *Handler
+}
+
+func (q *graphQLResolver) Concept(ctx context.Context, args struct{ ID graphql.ID }) (*conceptIdentifiersResolver, error) {
+	if err := spend(ctx, 1); err != nil {
+		return nil, err
+	}
+	c, found, err := q.h.driver.ReadByConceptID(ctx, []string{strings.TrimPrefix(string(args.ID), thingURIPrefix)})
+	if err != nil || !found {
+		return nil, err
+	}
+	c = filterVisible(ctx, c)
+	if len(c.Concordance) == 0 {
+		return nil, nil
+	}
+	SortConcordances(c.Concordance, DefaultSortOrder)
+	grouped := groupConcordances(c)
+	return &conceptIdentifiersResolver{grouped.Concepts[0]}, nil
+}
+
+func (q *graphQLResolver) Concordances(ctx context.Context, args struct{ ConceptIds []graphql.ID }) ([]*concordanceResolver, error) {
+	if len(args.ConceptIds) == 0 {
+		return []*concordanceResolver{}, nil
+	}
+	if q.h.tooManyIDs(len(args.ConceptIds)) {
+		return nil, errors.New(tooManyIDsInRequest)
+	}
+	if err := spend(ctx, len(args.ConceptIds)); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(args.ConceptIds))
+	for i, id := range args.ConceptIds {
+		ids[i] = strings.TrimPrefix(string(id), thingURIPrefix)
+	}
+	c, _, err := q.h.driver.ReadByConceptID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return newConcordanceResolvers(filterVisible(ctx, c)), nil
+}
+
+func (q *graphQLResolver) Lookup(ctx context.Context, args struct {
+	Authority string
+	Values    []string
+}) ([]*concordanceResolver, error) {
+	if len(args.Values) == 0 {
+		return []*concordanceResolver{}, nil
+	}
+	if !canSee(ctx, args.Authority) {
+		return nil, errors.New(authorityNotPermitted)
+	}
+	if q.h.tooManyIDs(len(args.Values)) {
+		return nil, errors.New(tooManyIDsInRequest)
+	}
+	if err := spend(ctx, len(args.Values)); err != nil {
+		return nil, err
+	}
+	c, _, err := q.h.driver.ReadByAuthority(ctx, args.Authority, args.Values)
+	if err != nil {
+		return nil, err
+	}
+	return newConcordanceResolvers(c), nil
+}
+
+func newConcordanceResolvers(c Concordances) []*concordanceResolver {
+	SortConcordances(c.Concordance, DefaultSortOrder)
+	resolvers := make([]*concordanceResolver, len(c.Concordance))
+	for i, con := range c.Concordance {
+		resolvers[i] = &concordanceResolver{con}
+	}
+	return resolvers
+}
+
+type concordanceResolver struct {
+	c Concordance
+}
+
+func (r *concordanceResolver) Concept() *conceptResolver {
+	return &conceptResolver{r.c.Concept}
+}
+
+func (r *concordanceResolver) Identifier() *identifierResolver {
+	return &identifierResolver{r.c.Identifier}
+}
+
+type conceptResolver struct {
+	c Concept
+}
+
+func (r *conceptResolver) ID() graphql.ID {
+	return graphql.ID(r.c.ID)
+}
+
+func (r *conceptResolver) APIURL() string {
+	return r.c.APIURL
+}
+
+func (r *conceptResolver) RequestedIds() []graphql.ID {
+	return toGraphQLIDs(r.c.RequestedIDs)
+}
+
+func (r *conceptResolver) MergedFrom() []graphql.ID {
+	return toGraphQLIDs(r.c.MergedFrom)
+}
+
+type conceptIdentifiersResolver struct {
+	c ConceptIdentifiers
+}
+
+func (r *conceptIdentifiersResolver) ID() graphql.ID {
+	return graphql.ID(r.c.ID)
+}
+
+func (r *conceptIdentifiersResolver) APIURL() string {
+	return r.c.APIURL
+}
+
+func (r *conceptIdentifiersResolver) RequestedIds() []graphql.ID {
+	return toGraphQLIDs(r.c.RequestedIDs)
+}
+
+func (r *conceptIdentifiersResolver) MergedFrom() []graphql.ID {
+	return toGraphQLIDs(r.c.MergedFrom)
+}
+
+func (r *conceptIdentifiersResolver) Identifiers() []*identifierResolver {
+	resolvers := make([]*identifierResolver, len(r.c.Identifiers))
+	for i, id := range r.c.Identifiers {
+		resolvers[i] = &identifierResolver{id}
+	}
+	return resolvers
+}
+
+type identifierResolver struct {
+	i Identifier
+}
+
+func (r *identifierResolver) Authority() string {
+	return r.i.Authority
+}
+
+func (r *identifierResolver) IdentifierValue() string {
+	return r.i.IdentifierValue
+}
+
+func toGraphQLIDs(ids []string) []graphql.ID {
+	gids := make([]graphql.ID, len(ids))
+	for i, id := range ids {
+		gids[i] = graphql.ID(id)
+	}
+	return gids
+}
@@ -0,0 +1,95 @@
+package concordances
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, query string) graphQLResponse {
+	srv := httptest.NewServer(NewHandler(mockConcordanceDriver{}).graphQLHandler())
+	defer srv.Close()
+
+	body, _ := json.Marshal(map[string]string{"query": query})
+	res, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 200, res.StatusCode)
+
+	var decoded graphQLResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&decoded))
+	return decoded
+}
+
+func TestGraphQLConceptReturnsGroupedIdentifiers(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandTMEUPP, concordedBrandSmartlogic, concordedBrandTME}}
+
+	res := postGraphQL(t, `{ concept(id: "http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad") { id identifiers { authority } } }`)
+	assert.Empty(res.Errors)
+	assert.Equal([]string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"}, conceptIds)
+	assert.JSONEq(`{"concept": {
+		"id": "http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+		"identifiers": [
+			{"authority": "http://api.ft.com/system/FT-TME"},
+			{"authority": "http://api.ft.com/system/SMARTLOGIC"},
+			{"authority": "http://api.ft.com/system/UPP"}
+		]}}`, string(res.Data))
+}
+
+func TestGraphQLConceptIsNullWhenNotFound(t *testing.T) {
+	assert := assert.New(t)
+	isFound = false
+	defer func() { isFound = true }()
+
+	res := postGraphQL(t, `{ concept(id: "unknown") { id } }`)
+	assert.Empty(res.Errors)
+	assert.JSONEq(`{"concept": null}`, string(res.Data))
+}
+
+func TestGraphQLLookupMapsOntoReadByAuthority(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandTME}}
+
+	res := postGraphQL(t, `{ lookup(authority: "http://api.ft.com/system/FT-TME", values: ["VGhlIFJvbWFu-QnJhbmRz", "other"]) { concept { apiUrl } identifier { identifierValue } } }`)
+	assert.Empty(res.Errors)
+	assert.Equal("http://api.ft.com/system/FT-TME", actualAuthority)
+	assert.Equal([]string{"VGhlIFJvbWFu-QnJhbmRz", "other"}, authorityValues)
+	assert.JSONEq(`{"lookup": [{
+		"concept": {"apiUrl": "http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		"identifier": {"identifierValue": "VGhlIFJvbWFu-QnJhbmRz"}
+	}]}`, string(res.Data))
+}
+
+func TestGraphQLRejectsQueriesOverTheCostBudget(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+
+	ids := make([]string, graphQLMaxCost/2+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%q", fmt.Sprintf("id-%d", i))
+	}
+	list := "[" + strings.Join(ids, ",") + "]"
+	query := fmt.Sprintf(`{ a: concordances(conceptIds: %s) { concept { id } } b: concordances(conceptIds: %s) { concept { id } } }`, list, list)
+
+	res := postGraphQL(t, query)
+	if assert.Len(res.Errors, 1) {
+		assert.Equal(errGraphQLTooComplex.Error(), res.Errors[0].Message)
+	}
+}
@@ -0,0 +1,18 @@
+package concordances
+
+// groupConcordances collects the identifiers of each concept together, keeping concepts and identifiers in the
+// order they first appear
+func groupConcordances(c Concordances) GroupedConcordances {
+	grouped := GroupedConcordances{Concepts: []ConceptIdentifiers{}}
+	index := map[string]int{}
+	for _, con := range c.Concordance {
+		i, ok := index[con.Concept.ID]
+		if !ok {
+			i = len(grouped.Concepts)
+			index[con.Concept.ID] = i
+			grouped.Concepts = append(grouped.Concepts, ConceptIdentifiers{ID: con.Concept.ID, APIURL: con.Concept.APIURL, RequestedIDs: con.Concept.RequestedIDs, MergedFrom: con.Concept.MergedFrom})
+		}
+		grouped.Concepts[i].Identifiers = append(grouped.Concepts[i].Identifiers, con.Identifier)
+	}
+	return grouped
+}
@@ -0,0 +1,55 @@
+package concordances
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupConcordancesListsEachConceptOnce(t *testing.T) {
+	grouped := groupConcordances(Concordances{[]Concordance{concordedBrandSmartlogic, unconcordedBrandTME, concordedBrandTME, unconcordedBrandTMEUPP}})
+
+	assert.Equal(t, GroupedConcordances{Concepts: []ConceptIdentifiers{
+		{
+			ID:          "http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+			APIURL:      "http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+			Identifiers: []Identifier{concordedBrandSmartlogic.Identifier, concordedBrandTME.Identifier},
+		},
+		{
+			ID:          "http://api.ft.com/things/ad56856a-7d38-48e2-a131-7d104f17e8f6",
+			APIURL:      "http://api.ft.com/brands/ad56856a-7d38-48e2-a131-7d104f17e8f6",
+			Identifiers: []Identifier{unconcordedBrandTME.Identifier, unconcordedBrandTMEUPP.Identifier},
+		},
+	}}, grouped)
+}
+
+func TestCanGetGroupedFormat(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandTMEUPP, concordedBrandSmartlogic, concordedBrandTME}}
+
+	res, err := http.Get(concordanceURL + "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad&format=grouped")
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+
+	body, _ := ioutil.ReadAll(res.Body)
+	var grouped GroupedConcordances
+	assert.NoError(json.Unmarshal(body, &grouped))
+	if assert.Len(grouped.Concepts, 1) {
+		assert.Equal("http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad", grouped.Concepts[0].ID)
+		assert.Equal([]Identifier{concordedBrandTME.Identifier, concordedBrandSmartlogic.Identifier, concordedBrandTMEUPP.Identifier}, grouped.Concepts[0].Identifiers)
+	}
+}
+
+func TestReturnBadRequestGivenUnknownFormat(t *testing.T) {
+	assert := assert.New(t)
+	res, err := http.Get(concordanceURL + "?conceptId=bob&format=xml")
+	assert.NoError(err)
+	assert.EqualValues(400, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), invalidFormat)
+}
@@ -0,0 +1,321 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/public-concordances-api/concordancespb"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer implements the concordancespb.ConcordancesServer, answering lookups with the driver and settings
+// of a Handler
+type GRPCServer struct {
+	concordancespb.UnimplementedConcordancesServer
+	handler *Handler
+}
+
+// NewGRPCServer returns a gRPC server with the concordances, health and reflection services registered.
+// The health service reports the Neo4j connectivity check of handler, refreshed every checkInterval. If auth is not nil,
+// every call other than a health check must carry credentials it accepts in its metadata, as REST requests do in their
+// headers. If policies is not nil, the visibility policy is applied to every call, with the entitlements of the
+// authenticated client.
+func NewGRPCServer(handler *Handler, checkInterval time.Duration, auth Authenticator, policies *PolicyStore) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryServerInterceptor(handler, auth, policies)),
+		grpc.ChainStreamInterceptor(streamServerInterceptor(handler, auth, policies)),
+	)
+	concordancespb.RegisterConcordancesServer(s, &GRPCServer{handler: handler})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	updateGRPCHealth(handler, healthServer)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		for range ticker.C {
+			updateGRPCHealth(handler, healthServer)
+		}
+	}()
+
+	reflection.Register(s)
+	return s
+}
+
+func updateGRPCHealth(handler *Handler, healthServer *health.Server) {
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if !handler.GTG().GoodToGo {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	healthServer.SetServingStatus("", servingStatus)
+	healthServer.SetServingStatus(concordancespb.Concordances_ServiceDesc.ServiceName, servingStatus)
+}
+
+// GetConceptConcordances returns every identifier of each requested concept
+func (s *GRPCServer) GetConceptConcordances(ctx context.Context, req *concordancespb.ConceptConcordancesRequest) (*concordancespb.ConcordancesResponse, error) {
+	concordances, err := s.readConceptConcordances(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &concordancespb.ConcordancesResponse{Concordances: toProtoConcordances(concordances)}, nil
+}
+
+// GetAuthorityConcordances returns the concepts for identifier values in a single authority
+func (s *GRPCServer) GetAuthorityConcordances(ctx context.Context, req *concordancespb.AuthorityConcordancesRequest) (*concordancespb.ConcordancesResponse, error) {
+	concordances, err := s.readAuthorityConcordances(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &concordancespb.ConcordancesResponse{Concordances: toProtoConcordances(concordances)}, nil
+}
+
+// StreamConceptConcordances sends each identifier of each requested concept as its own message
+func (s *GRPCServer) StreamConceptConcordances(req *concordancespb.ConceptConcordancesRequest, stream concordancespb.Concordances_StreamConceptConcordancesServer) error {
+	concordances, err := s.readConceptConcordances(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	return sendConcordances(concordances, stream.Send)
+}
+
+// StreamAuthorityConcordances sends the concept for each requested identifier as its own message
+func (s *GRPCServer) StreamAuthorityConcordances(req *concordancespb.AuthorityConcordancesRequest, stream concordancespb.Concordances_StreamAuthorityConcordancesServer) error {
+	concordances, err := s.readAuthorityConcordances(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	return sendConcordances(concordances, stream.Send)
+}
+
+func (s *GRPCServer) readConceptConcordances(ctx context.Context, req *concordancespb.ConceptConcordancesRequest) (Concordances, error) {
+	if len(req.GetConceptIds()) == 0 {
+		return Concordances{}, status.Error(codes.InvalidArgument, conceptIDsRequired)
+	}
+	if s.handler.tooManyIDs(len(req.GetConceptIds())) {
+		return Concordances{}, status.Error(codes.InvalidArgument, tooManyIDsInRequest)
+	}
+	ids := make([]string, len(req.GetConceptIds()))
+	for i, id := range req.GetConceptIds() {
+		ids[i] = strings.TrimPrefix(id, thingURIPrefix)
+	}
+	concordances, _, err := s.handler.driver.ReadByConceptID(ctx, ids)
+	setStaleMetadata(ctx)
+	if err != nil {
+		return Concordances{}, driverErrorStatus(err)
+	}
+	concordances = filterVisible(ctx, concordances)
+	SortConcordances(concordances.Concordance, DefaultSortOrder)
+	return concordances, nil
+}
+
+func (s *GRPCServer) readAuthorityConcordances(ctx context.Context, req *concordancespb.AuthorityConcordancesRequest) (Concordances, error) {
+	if req.GetAuthority() == "" || len(req.GetIdentifierValues()) == 0 {
+		return Concordances{}, status.Error(codes.InvalidArgument, authorityAndValuesRequired)
+	}
+	if s.handler.tooManyIDs(len(req.GetIdentifierValues())) {
+		return Concordances{}, status.Error(codes.InvalidArgument, tooManyIDsInRequest)
+	}
+	if !canSee(ctx, req.GetAuthority()) {
+		return Concordances{}, status.Error(codes.PermissionDenied, authorityNotPermitted)
+	}
+	concordances, _, err := s.handler.driver.ReadByAuthority(ctx, req.GetAuthority(), req.GetIdentifierValues())
+	setStaleMetadata(ctx)
+	if err != nil {
+		return Concordances{}, driverErrorStatus(err)
+	}
+	concordances = filterVisible(ctx, concordances)
+	SortConcordances(concordances.Concordance, DefaultSortOrder)
+	return concordances, nil
+}
+
+// setStaleMetadata is the gRPC equivalent of setStaleHeader, sending the header in the response metadata
+func setStaleMetadata(ctx context.Context) {
+	if servedFromFallback(ctx) {
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(staleHeader), "true"))
+	}
+}
+
+// driverErrorStatus tells clients to back off and retry queries shed under load
+func driverErrorStatus(err error) error {
+	if errors.Is(err, ErrOverloaded) {
+		return status.Error(codes.Unavailable, serviceOverloaded)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func sendConcordances(concordances Concordances, send func(*concordancespb.Concordance) error) error {
+	for _, c := range concordances.Concordance {
+		if err := send(toProtoConcordance(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toProtoConcordances(concordances Concordances) []*concordancespb.Concordance {
+	pbs := make([]*concordancespb.Concordance, len(concordances.Concordance))
+	for i, c := range concordances.Concordance {
+		pbs[i] = toProtoConcordance(c)
+	}
+	return pbs
+}
+
+func toProtoConcordance(c Concordance) *concordancespb.Concordance {
+	return &concordancespb.Concordance{
+		Concept: &concordancespb.Concept{
+			Id:           c.Concept.ID,
+			ApiUrl:       c.Concept.APIURL,
+			RequestedIds: c.Concept.RequestedIDs,
+			MergedFrom:   c.Concept.MergedFrom,
+		},
+		Identifier: &concordancespb.Identifier{
+			Authority:       c.Identifier.Authority,
+			IdentifierValue: c.Identifier.IdentifierValue,
+		},
+	}
+}
+
+func unaryServerInterceptor(h *Handler, auth Authenticator, policies *PolicyStore) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, finish, err := startCall(ctx, info.FullMethod, h, auth, policies)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		finish(err)
+		return resp, err
+	}
+}
+
+func streamServerInterceptor(h *Handler, auth Authenticator, policies *PolicyStore) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, finish, err := startCall(ss.Context(), info.FullMethod, h, auth, policies)
+		if err != nil {
+			return err
+		}
+		err = handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		finish(err)
+		return err
+	}
+}
+
+// startCall is the gRPC equivalent of AuthMiddleware, PolicyMiddleware and startRequest, authenticating and tracing
+// the call and logging its outcome once finished. It returns an Unauthenticated error for calls it rejects.
+func startCall(ctx context.Context, method string, h *Handler, auth Authenticator, policies *PolicyStore) (context.Context, func(error), error) {
+	start := h.now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	tid := firstMetadataValue(md, strings.ToLower(transactionidutils.TransactionIDHeader))
+	if tid == "" {
+		tid = transactionidutils.NewTransactionID()
+	}
+	ctx = withFallbackMarker(transactionidutils.TransactionAwareContext(ctx, tid))
+	ctx = withAuthorities(ctx, h.currentAuthorities())
+
+	var client *ClientIdentity
+	// Health checks come from the platform, which has no credentials, as the admin endpoints do over HTTP
+	if auth != nil && !strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		var err error
+		if client, err = auth.Authenticate(metadataRequest(ctx, method, md)); err != nil {
+			rejectedRequests.Inc(1)
+			log.WithTransactionID(tid).
+				WithField("grpc_method", method).
+				WithError(err).
+				Warn("Rejected unauthenticated gRPC call")
+			return ctx, nil, status.Error(codes.Unauthenticated, authenticationRequired)
+		}
+		markClientRequest(client)
+		ctx = withClient(ctx, client)
+	}
+	if policies != nil {
+		// Calls don't pass through the API gateway, which overwrites the entitlements header of REST requests, so
+		// the header is whatever the caller chose to send and only the client's own entitlements are trusted
+		ctx = withViewer(ctx, policies.Policy().viewerFor(client, nil))
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc")))
+
+	return ctx, func(err error) {
+		code := status.Code(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+		endSpan(span, err)
+
+		fields := map[string]interface{}{
+			"grpc_method": method,
+			"grpc_code":   code.String(),
+			"latency_ms":  h.now().Sub(start).Seconds() * 1000,
+		}
+		if client != nil {
+			fields["client_id"] = client.ID
+		}
+		entry := log.WithTransactionID(tid).WithFields(fields)
+		switch code {
+		case codes.OK, codes.InvalidArgument, codes.PermissionDenied, codes.Canceled, codes.Unavailable:
+			entry.Info("Concordance gRPC call completed")
+		default:
+			entry.WithError(err).Error("Concordance gRPC call failed")
+		}
+	}, nil
+}
+
+// metadataRequest presents the metadata of a call as the headers of a request, for an Authenticator to check
+func metadataRequest(ctx context.Context, method string, md metadata.MD) *http.Request {
+	r := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: http.Header{}}
+	for key, values := range md {
+		for _, v := range values {
+			r.Header.Add(key, v)
+		}
+	}
+	return r.WithContext(ctx)
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextServerStream replaces the context of a stream with one carrying the call's span and transaction ID
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier lets trace context be propagated through incoming gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return firstMetadataValue(metadata.MD(c), key)
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,168 @@
+package concordances
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/public-concordances-api/concordancespb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newGRPCClient(t *testing.T) *grpc.ClientConn {
+	return newAuthGRPCClient(t, nil, nil)
+}
+
+func newAuthGRPCClient(t *testing.T, auth Authenticator, policies *PolicyStore) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(NewHandler(mockConcordanceDriver{}), time.Hour, auth, policies)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCGetConceptConcordances(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandTMEUPP, concordedBrandTME}}
+
+	client := concordancespb.NewConcordancesClient(newGRPCClient(t))
+	resp, err := client.GetConceptConcordances(context.Background(), &concordancespb.ConceptConcordancesRequest{
+		ConceptIds: []string{"http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"}, conceptIds)
+	if assert.Len(resp.GetConcordances(), 2) {
+		assert.Equal("http://api.ft.com/system/FT-TME", resp.GetConcordances()[0].GetIdentifier().GetAuthority())
+		assert.Equal("http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad", resp.GetConcordances()[0].GetConcept().GetApiUrl())
+		assert.Equal("http://api.ft.com/system/UPP", resp.GetConcordances()[1].GetIdentifier().GetAuthority())
+	}
+}
+
+func TestGRPCStreamAuthorityConcordances(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandTME, unconcordedBrandTME}}
+
+	client := concordancespb.NewConcordancesClient(newGRPCClient(t))
+	stream, err := client.StreamAuthorityConcordances(context.Background(), &concordancespb.AuthorityConcordancesRequest{
+		Authority:        "http://api.ft.com/system/FT-TME",
+		IdentifierValues: []string{"VGhlIFJvbWFu-QnJhbmRz", "UGFydHkgcGVvcGxl-QnJhbmRz"},
+	})
+	assert.NoError(err)
+
+	var received []string
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(err) {
+			return
+		}
+		received = append(received, c.GetIdentifier().GetIdentifierValue())
+	}
+	assert.Equal("http://api.ft.com/system/FT-TME", actualAuthority)
+	assert.Equal([]string{"UGFydHkgcGVvcGxl-QnJhbmRz", "VGhlIFJvbWFu-QnJhbmRz"}, received)
+}
+
+func TestGRPCRejectsEmptyRequests(t *testing.T) {
+	assert := assert.New(t)
+	client := concordancespb.NewConcordancesClient(newGRPCClient(t))
+
+	_, err := client.GetConceptConcordances(context.Background(), &concordancespb.ConceptConcordancesRequest{})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetAuthorityConcordances(context.Background(), &concordancespb.AuthorityConcordancesRequest{Authority: "http://api.ft.com/system/FT-TME"})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCHealthReportsNeo4jConnectivity(t *testing.T) {
+	assert := assert.New(t)
+	client := healthpb.NewHealthClient(newGRPCClient(t))
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "ft.concordances.v1.Concordances"})
+	assert.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
+
+func TestGRPCAuthenticatesCallsAndRestrictsClients(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME, concordedBrandTMEUPP}}
+
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	conn := newAuthGRPCClient(t, NewAPIKeyAuthenticator(clients), nil)
+	client := concordancespb.NewConcordancesClient(conn)
+	req := &concordancespb.ConceptConcordancesRequest{ConceptIds: []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"}}
+
+	_, err = client.GetConceptConcordances(context.Background(), req)
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.GetConceptConcordances(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong-key"), req)
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+	stream, err := client.StreamConceptConcordances(context.Background(), req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(codes.Unauthenticated, status.Code(err))
+
+	partner := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "partner-key")
+	resp, err := client.GetConceptConcordances(partner, req)
+	if assert.NoError(err) {
+		assert.Len(resp.GetConcordances(), 2)
+	}
+	_, err = client.GetAuthorityConcordances(partner, &concordancespb.AuthorityConcordancesRequest{Authority: "http://api.ft.com/system/SMARTLOGIC", IdentifierValues: []string{"x"}})
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+
+	health, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+}
+
+func TestGRPCIgnoresTheEntitlementsHeader(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME, concordedBrandTMEUPP}}
+
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	store, err := NewPolicyStore(writeTestFile(t, "policy.json", testPolicyFile))
+	require.NoError(t, err)
+	client := concordancespb.NewConcordancesClient(newAuthGRPCClient(t, NewAPIKeyAuthenticator(clients), store))
+	req := &concordancespb.ConceptConcordancesRequest{ConceptIds: []string{"b20801ac-5a76-43cf-b816-8c3b2f7133ad"}}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "search-key", "x-ft-entitlements", "everything")
+	resp, err := client.GetConceptConcordances(ctx, req)
+	if assert.NoError(err) {
+		var authorities []string
+		for _, c := range resp.GetConcordances() {
+			authorities = append(authorities, c.GetIdentifier().GetAuthority())
+		}
+		assert.Equal([]string{"http://api.ft.com/system/FT-TME", "http://api.ft.com/system/SMARTLOGIC"}, authorities, "only the client's entitlements apply")
+	}
+	_, err = client.GetAuthorityConcordances(ctx, &concordancespb.AuthorityConcordancesRequest{Authority: "http://api.ft.com/system/UPP", IdentifierValues: []string{"x"}})
+	assert.Equal(codes.PermissionDenied, status.Code(err))
+}
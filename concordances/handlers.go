@@ -1,9 +1,15 @@
 package concordances
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"errors"
 	"strings"
@@ -11,18 +17,136 @@ import (
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	log "github.com/Financial-Times/go-logger"
 	"github.com/Financial-Times/service-status-go/gtg"
-	log "github.com/sirupsen/logrus"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ConcordanceDriver for cypher queries
-var ConcordanceDriver Driver
-var CacheControlHeader string
-var connCheck error
+// Handler serves the concordances API from a Driver. Each Handler has its own settings and health checks,
+// so several can be run in one process, e.g. against different Neo4j instances.
+type Handler struct {
+	driver            Driver
+	now               func() time.Time
+	maxIDsPerRequest  int
+	payloadSampleRate uint64
+	payloadCounter    uint64
+	cacheControl      atomic.Value
+	authorities       atomic.Value
+
+	mu         sync.RWMutex
+	connCheck  error
+	indexCheck error
+}
+
+// HandlerOption configures a Handler
+type HandlerOption func(*Handler)
+
+// WithCacheControl sets the Cache-Control header of successful responses
+func WithCacheControl(header string) HandlerOption {
+	return func(h *Handler) {
+		h.SetCacheControlHeader(header)
+	}
+}
+
+// WithAuthorities adds authorities to, or changes the URIs of, the built in ones identifiers are concorded from.
+// Authorities are named as in Neo4j, e.g. {"ORCID": "http://api.ft.com/system/ORCID"}.
+func WithAuthorities(extra map[string]string) HandlerOption {
+	return func(h *Handler) {
+		h.SetAuthorities(extra)
+	}
+}
+
+// WithMaxIDsPerRequest caps the number of concept IDs or identifier values accepted in one request. 0, the default,
+// is unlimited.
+func WithMaxIDsPerRequest(n int) HandlerOption {
+	return func(h *Handler) {
+		h.maxIDsPerRequest = n
+	}
+}
+
+// WithDebugPayloadSampleRate controls how often large response bodies are written to the debug log: 1 in every n.
+// The default is 100.
+func WithDebugPayloadSampleRate(n uint64) HandlerOption {
+	return func(h *Handler) {
+		h.payloadSampleRate = n
+	}
+}
+
+// WithClock replaces time.Now, which is used to time requests
+func WithClock(now func() time.Time) HandlerOption {
+	return func(h *Handler) {
+		h.now = now
+	}
+}
+
+// NewHandler returns a Handler answering lookups from driver
+func NewHandler(driver Driver, opts ...HandlerOption) *Handler {
+	h := &Handler{driver: driver, now: time.Now, payloadSampleRate: 100}
+	h.SetCacheControlHeader("")
+	h.SetAuthorities(nil)
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// RegisterRoutes adds the API endpoints to r. The admin endpoints are left to the caller, see HealthCheck and GTG.
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/concordances", h.GetConcordances).Methods("GET")
+	r.HandleFunc("/concepts/{uuid}/concordances", h.GetConceptConcordances).Methods("GET")
+	r.HandleFunc("/authorities/{authority}/identifiers/{value:.+}", h.GetIdentifierConcordances).Methods("GET")
+	r.Handle("/graphql", h.graphQLHandler()).Methods("POST")
+}
+
+// SetCacheControlHeader changes the Cache-Control header of successful responses
+func (h *Handler) SetCacheControlHeader(header string) {
+	h.cacheControl.Store(header)
+}
+
+// SetAuthorities changes the authorities added to the built in ones, see WithAuthorities
+func (h *Handler) SetAuthorities(extra map[string]string) {
+	h.authorities.Store(withExtraAuthorities(extra))
+}
+
+func (h *Handler) currentAuthorities() authorities {
+	return h.authorities.Load().(authorities)
+}
+
+// CacheControlForDuration is the Cache-Control header allowing public caching for d
+func CacheControlForDuration(d time.Duration) string {
+	return fmt.Sprintf("max-age=%s, public", strconv.FormatFloat(d.Seconds(), 'f', 0, 64))
+}
+
+func (h *Handler) cacheControlHeader() string {
+	return h.cacheControl.Load().(string)
+}
+
+// cacheControlFor is the Cache-Control header of a successful response. Responses answered from a fallback are only
+// stored by caches which check for fresh concordances each time, as Neo4j may have recovered by the next request.
+// Responses filtered for the caller's client or entitlements are marked private, so shared caches don't serve them to
+// other callers.
+func (h *Handler) cacheControlFor(ctx context.Context) string {
+	header := h.cacheControlHeader()
+	if servedFromFallback(ctx) {
+		header = "no-cache"
+	}
+	if clientFromContext(ctx) == nil && viewerFromContext(ctx) == nil {
+		return header
+	}
+	if strings.Contains(header, "public") {
+		return strings.Replace(header, "public", "private", 1)
+	}
+	return header + ", private"
+}
 
 // HealthCheck provides an FT standard timed healthcheck for the /__health endpoint
-func HealthCheck() fthealth.TimedHealthCheck {
-	return fthealth.TimedHealthCheck{
+func (h *Handler) HealthCheck() fthealth.TimedHealthCheck {
+	check := fthealth.TimedHealthCheck{
 		HealthCheck: fthealth.HealthCheck{
 			SystemCode:  "public-concordances-api",
 			Name:        "public-concordances-api",
@@ -34,85 +158,332 @@ func HealthCheck() fthealth.TimedHealthCheck {
 					PanicGuide:       "https://dewey.in.ft.com/view/system/public-concordances-api",
 					Severity:         1,
 					TechnicalSummary: "Cannot connect to Neo4j a instance with at least one concordance loaded in it",
-					Checker:          Checker,
+					Checker:          h.Checker,
+				},
+				{
+					BusinessImpact:   "Concordance lookups will be slow and may time out, increasing load on Neo4j for all its clients",
+					Name:             "Check Neo4j indexes used by concordance queries",
+					PanicGuide:       "https://dewey.in.ft.com/view/system/public-concordances-api",
+					Severity:         2,
+					TechnicalSummary: "One or more indexes on :Thing(uuid), :Thing(authority), :Thing(authorityValue), :Concept(leiCode) or :Location(iso31661) is missing or not online. Check the output of CALL db.indexes() in Neo4j and create the missing indexes, or restart the service with CREATE_MISSING_INDEXES=true against a writable instance",
+					Checker:          h.IndexesChecker,
 				},
 			},
 		},
 		Timeout: 10 * time.Second,
 	}
+	if endpoints, ok := findDriver(h.driver, func(d Driver) bool {
+		_, ok := d.(EndpointChecker)
+		return ok
+	}).(EndpointChecker); ok {
+		for _, name := range endpoints.Endpoints() {
+			name := name
+			check.Checks = append(check.Checks, fthealth.Check{
+				BusinessImpact:   "Reads are spread over fewer Neo4j instances, increasing the load on the rest",
+				Name:             "Check Neo4j endpoint " + name,
+				PanicGuide:       "https://dewey.in.ft.com/view/system/public-concordances-api",
+				Severity:         2,
+				TechnicalSummary: "Queries to " + name + " have been failing, so it has been ejected and reads are sent to the other Neo4j endpoints. It is tried again once its connectivity check passes",
+				Checker: func() (string, error) {
+					if err := endpoints.CheckEndpoint(name); err != nil {
+						return "Neo4j endpoint " + name + " has been ejected", err
+					}
+					return "Neo4j endpoint " + name + " is in use", nil
+				},
+			})
+		}
+	}
+	if _, ok := h.driver.(FallbackChecker); ok {
+		check.Checks = append(check.Checks, fthealth.Check{
+			BusinessImpact:   "Concordances may be out of date, and lookups which weren't made recently may fail",
+			Name:             "Check concordances are served from the primary Neo4j instance",
+			PanicGuide:       "https://dewey.in.ft.com/view/system/public-concordances-api",
+			Severity:         2,
+			TechnicalSummary: "Queries to the primary Neo4j instance are failing, so responses are stale, served from the fallback and marked with the " + staleHeader + " header. Check the connectivity check and the Neo4j instance",
+			Checker:          h.FallbackChecker,
+		})
+	}
+	return check
+}
+
+// findDriver returns the first driver in the chain of decorators starting at d which matches, or nil
+func findDriver(d Driver, match func(Driver) bool) Driver {
+	for d != nil {
+		if match(d) {
+			return d
+		}
+		unwrapper, ok := d.(interface{ Unwrap() Driver })
+		if !ok {
+			return nil
+		}
+		d = unwrapper.Unwrap()
+	}
+	return nil
 }
 
-func StartAsyncChecker(checkInterval time.Duration) {
-	go func(checkInterval time.Duration) {
+// FallbackChecker warns while results are being served from a fallback driver
+func (h *Handler) FallbackChecker() (string, error) {
+	checker, ok := h.driver.(FallbackChecker)
+	if !ok {
+		return "Concordances are served from the primary store", nil
+	}
+	if err := checker.CheckFallback(); err != nil {
+		return "Concordances are stale, served from the fallback", err
+	}
+	return "Concordances are served from the primary store", nil
+}
+
+// StartAsyncChecker refreshes the results of the health checks every checkInterval until stopped
+func (h *Handler) StartAsyncChecker(checkInterval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		h.setIndexCheck(h.checkIndexes())
 		ticker := time.NewTicker(checkInterval)
-		for range ticker.C {
-			connCheck = ConcordanceDriver.CheckConnectivity()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				h.setConnCheck(h.driver.CheckConnectivity())
+				h.setIndexCheck(h.checkIndexes())
+			}
 		}
-	}(checkInterval)
+	}()
+	return func() { close(done) }
+}
+
+func (h *Handler) setConnCheck(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connCheck = err
+}
+
+func (h *Handler) setIndexCheck(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.indexCheck = err
+}
+
+func (h *Handler) checkIndexes() error {
+	checker, ok := h.driver.(IndexChecker)
+	if !ok {
+		return nil
+	}
+	err := checker.CheckIndexes()
+	if err != nil {
+		log.WithError(err).Warn("Neo4j index check failed")
+	}
+	return err
 }
 
 // Checker does more stuff
-func Checker() (string, error) {
-	if connCheck == nil {
-		return "Connectivity to neo4j is ok", connCheck
+func (h *Handler) Checker() (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.connCheck == nil {
+		return "Connectivity to neo4j is ok", nil
 	}
-	return "Error connecting to neo4j", connCheck
+	return "Error connecting to neo4j", h.connCheck
 }
 
-// GTG lightly checks the application and conforms to the FT standard GTG format
-func GTG() gtg.Status {
-	if _, err := Checker(); err != nil {
+// IndexesChecker reports whether the indexes the concordance queries rely on are present
+func (h *Handler) IndexesChecker() (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.indexCheck == nil {
+		return "All required Neo4j indexes are online", nil
+	}
+	return "Required Neo4j indexes are missing", h.indexCheck
+}
+
+// GTG lightly checks the application and conforms to the FT standard GTG format. It stays good while Neo4j is down
+// if a fallback driver can answer instead, so that the service stays in rotation.
+func (h *Handler) GTG() gtg.Status {
+	if _, err := h.Checker(); err != nil && !h.canFallBack() {
 		return gtg.Status{GoodToGo: false, Message: err.Error()}
 	}
 	return gtg.Status{GoodToGo: true}
 }
 
+func (h *Handler) canFallBack() bool {
+	checker, ok := h.driver.(FallbackChecker)
+	return ok && checker.CheckSecondary() == nil
+}
+
 // GetConcordances is the public API
-func GetConcordances(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetConcordances(w http.ResponseWriter, r *http.Request) {
+	ctx, span, reqLog := h.startRequest(w, r, "GetConcordances")
+	defer span.End()
 
-	log.Debugf("Concordance request: %s", r.URL.RawQuery)
 	m, _ := url.ParseQuery(r.URL.RawQuery)
 
 	_, conceptIDExist := m["conceptId"]
 	_, authorityExist := m["authority"]
+	span.SetAttributes(
+		attribute.Bool("concordances.by_concept_id", conceptIDExist),
+		attribute.Bool("concordances.by_authority", authorityExist),
+	)
+	switch {
+	case conceptIDExist && authorityExist:
+		reqLog.set("lookup_mode", "invalid")
+	case conceptIDExist:
+		reqLog.set("lookup_mode", "conceptId")
+		reqLog.set("input_count", len(m["conceptId"]))
+	case authorityExist:
+		reqLog.set("lookup_mode", "authority")
+		reqLog.set("authority", m.Get("authority"))
+		reqLog.set("input_count", len(m["identifierValue"]))
+	default:
+		reqLog.set("lookup_mode", "invalid")
+	}
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	if conceptIDExist && authorityExist {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(
-			`{"message": "` + conceptAndAuthorityCannotBeBothPresent + `"}`))
+		writeMessage(w, reqLog, http.StatusBadRequest, conceptAndAuthorityCannotBeBothPresent, nil)
 		return
 	}
 
 	if !conceptIDExist && !authorityExist {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(
-			`{"message": "` + authorityIsMandatoryIfConceptIdIsMissing + `"}`))
+		writeMessage(w, reqLog, http.StatusBadRequest, authorityIsMandatoryIfConceptIdIsMissing, nil)
 		return
 	}
 
 	if len(m["authority"]) > 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(
-			`{"message": "` + multipleAuthoritiesNotPermitted + `"}`))
+		writeMessage(w, reqLog, http.StatusBadRequest, multipleAuthoritiesNotPermitted, nil)
+		return
+	}
+
+	if h.tooManyIDs(len(m["conceptId"]) + len(m["identifierValue"])) {
+		writeMessage(w, reqLog, http.StatusBadRequest, tooManyIDsInRequest, nil)
+		return
+	}
+
+	if authorityExist && !canSee(ctx, m.Get("authority")) {
+		writeMessage(w, reqLog, http.StatusForbidden, authorityNotPermitted, nil)
+		return
+	}
+
+	opts, msg := parseResponseOptions(m)
+	if msg != "" {
+		writeMessage(w, reqLog, http.StatusBadRequest, msg, nil)
+		return
+	}
+	if opts.debug {
+		ctx = withDebug(ctx)
+	}
+
+	concordance, found, err := h.processParams(ctx, conceptIDExist, authorityExist, m)
+	setStaleHeader(ctx, w)
+	if err != nil {
+		endSpan(span, err)
+		writeDriverError(w, reqLog, err)
+		return
+	}
+
+	h.writeConcordances(ctx, w, r, reqLog, concordance, found, opts)
+}
+
+// startRequest begins the server span and request log shared by every concordance endpoint, and echoes the
+// request's transaction ID in the response
+func (h *Handler) startRequest(w http.ResponseWriter, r *http.Request, name string) (context.Context, trace.Span, *requestLog) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+	ctx = withAuthorities(withFallbackMarker(ctx), h.currentAuthorities())
+	reqLog := newRequestLog(r, h.now)
+	w.Header().Set(transactionidutils.TransactionIDHeader, reqLog.tid)
+	return ctx, span, reqLog
+}
+
+// setStaleHeader marks the response if it was built from a fallback driver's results
+func setStaleHeader(ctx context.Context, w http.ResponseWriter) {
+	if servedFromFallback(ctx) {
+		w.Header().Set(staleHeader, "true")
+	}
+}
+
+// responseOptions are the query parameters controlling how concordances are rendered
+type responseOptions struct {
+	sort   SortOrder
+	format string
+	debug  bool
+}
+
+// parseResponseOptions returns the message to respond with if any of the parameters are invalid
+func parseResponseOptions(m url.Values) (responseOptions, string) {
+	sortOrder, ok := ParseSortOrder(m.Get("sort"))
+	if !ok {
+		return responseOptions{}, invalidSortOrder
+	}
+
+	format := m.Get("format")
+	if format != "" && format != formatFlat && format != formatGrouped {
+		return responseOptions{}, invalidFormat
+	}
+
+	debug, _ := strconv.ParseBool(m.Get("debug"))
+	return responseOptions{sort: sortOrder, format: format, debug: debug}, ""
+}
+
+func writeMessage(w http.ResponseWriter, reqLog *requestLog, status int, message string, err error) {
+	reqLog.finish(status, false, err)
+	w.WriteHeader(status)
+	w.Write([]byte(`{"message": "` + message + `"}`))
+}
+
+// writeDriverError responds 503 to queries shed under load, so that clients back off and retry, and 500 otherwise
+func writeDriverError(w http.ResponseWriter, reqLog *requestLog, err error) {
+	if errors.Is(err, ErrOverloaded) {
+		w.Header().Set("Retry-After", retryAfterSeconds(overloadRetryAfter))
+		writeMessage(w, reqLog, http.StatusServiceUnavailable, serviceOverloaded, nil)
 		return
 	}
+	writeMessage(w, reqLog, http.StatusInternalServerError, err.Error(), err)
+}
+
+// writeConcordances renders concordances in the requested order and format, answering conditional requests
+func (h *Handler) writeConcordances(ctx context.Context, w http.ResponseWriter, r *http.Request, reqLog *requestLog, concordance Concordances, found bool, opts responseOptions) {
+	concordance = filterVisible(ctx, concordance)
+	// The caller may ask for a different order, and not every Driver sorts its results
+	SortConcordances(concordance.Concordance, opts.sort)
 
-	concordance, _, err := processParams(conceptIDExist, authorityExist, m)
+	_, encodeSpan := tracer.Start(ctx, "encodeResponse", trace.WithAttributes(
+		attribute.Int("concordances.results.count", len(concordance.Concordance)),
+	))
+	var response interface{} = concordance
+	if opts.format == formatGrouped {
+		response = groupConcordances(concordance)
+	}
+	body := &bytes.Buffer{}
+	err := json.NewEncoder(body).Encode(response)
+	endSpan(encodeSpan, err)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"message": "` + err.Error() + `"}`))
+		writeMessage(w, reqLog, http.StatusInternalServerError, err.Error(), err)
 		return
 	}
 
-	Jason, _ := json.Marshal(concordance)
-	log.Debugf("Concordance(uuid:%s): %s\n", Jason, Jason)
-	w.Header().Set("Cache-Control", CacheControlHeader)
+	reqLog.set("result_count", len(concordance.Concordance))
+
+	etag := computeETag(body.Bytes())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", h.cacheControlFor(ctx))
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		reqLog.finish(http.StatusNotModified, found, nil)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(concordance)
+	w.Write(body.Bytes())
+
+	h.logPayload(reqLog, len(concordance.Concordance), body.Bytes())
+	reqLog.finish(http.StatusOK, found, nil)
 }
 
-func processParams(conceptIDExist bool, authorityExist bool, m url.Values) (concordances Concordances, found bool, err error) {
+func (h *Handler) processParams(ctx context.Context, conceptIDExist bool, authorityExist bool, m url.Values) (concordances Concordances, found bool, err error) {
+	ctx, span := tracer.Start(ctx, "processParams")
+	defer func() { endSpan(span, err) }()
+
 	if conceptIDExist {
 		conceptUuids := []string{}
 
@@ -120,11 +491,11 @@ func processParams(conceptIDExist bool, authorityExist bool, m url.Values) (conc
 			conceptUuids = append(conceptUuids, strings.TrimPrefix(uri, thingURIPrefix))
 		}
 
-		return ConcordanceDriver.ReadByConceptID(conceptUuids)
+		return h.driver.ReadByConceptID(ctx, conceptUuids)
 	}
 
 	if authorityExist {
-		return ConcordanceDriver.ReadByAuthority(m.Get("authority"), m["identifierValue"])
+		return h.driver.ReadByAuthority(ctx, m.Get("authority"), m["identifierValue"])
 	}
 
 	return Concordances{}, false, errors.New(neitherConceptIdNorAuthorityPresent)
@@ -137,4 +508,18 @@ const (
 	conceptAndAuthorityCannotBeBothPresent   = "If conceptId is present then authority is not a valid parameter"
 	authorityIsMandatoryIfConceptIdIsMissing = "If conceptId is absent then authority is mandatory"
 	neitherConceptIdNorAuthorityPresent      = "Neither conceptId nor authority were present"
+	invalidSortOrder                         = "sort must be one of conceptId, authority or identifierValue"
+	invalidFormat                            = "format must be either flat or grouped"
+	conceptNotFound                          = "No concordances found for concept"
+	identifierNotFound                       = "No concept found for identifier"
+	conceptIDsRequired                       = "At least one concept ID is required"
+	authorityAndValuesRequired               = "An authority and at least one identifier value are required"
+	authenticationRequired                   = "A valid API key or bearer token is required"
+	authorityNotPermitted                    = "Client is not permitted to look up identifiers in this authority"
+	tooManyIDsInRequest                      = "Too many conceptId or identifierValue parameters"
+	rateLimitExceeded                        = "Rate limit exceeded, retry later"
+	serviceOverloaded                        = "Too many concurrent requests, retry later"
+
+	formatFlat    = "flat"
+	formatGrouped = "grouped"
 )
@@ -1,14 +1,24 @@
 package concordances
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/Financial-Times/go-logger"
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 var (
@@ -18,29 +28,51 @@ var (
 	conceptIds      []string
 	authorityValues []string
 	actualAuthority string
+	mockResult      Concordances
 )
 
 type mockConcordanceDriver struct{}
 
-func (driver mockConcordanceDriver) ReadByConceptID(ids []string) (concordances Concordances, found bool, err error) {
+func (driver mockConcordanceDriver) ReadByConceptID(ctx context.Context, ids []string) (concordances Concordances, found bool, err error) {
 	conceptIds = ids
-	return Concordances{}, isFound, nil
+	return copyConcordances(mockResult), isFound, nil
 }
-func (driver mockConcordanceDriver) ReadByAuthority(authority string, ids []string) (concordances Concordances, found bool, err error) {
+func (driver mockConcordanceDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (concordances Concordances, found bool, err error) {
 	authorityValues = ids
 	actualAuthority = authority
-	return Concordances{}, isFound, nil
+	return copyConcordances(mockResult), isFound, nil
+}
+
+// copyConcordances stops the handler's in-place ordering leaking back into mockResult between requests
+func copyConcordances(c Concordances) Concordances {
+	if c.Concordance == nil {
+		return Concordances{}
+	}
+	return Concordances{Concordance: append([]Concordance{}, c.Concordance...)}
 }
 
 func (driver mockConcordanceDriver) CheckConnectivity() error {
 	return nil
 }
 
-func init() {
-	ConcordanceDriver = mockConcordanceDriver{}
+// testCacheControl stands in for the Cache-Control header main always configures, which the API spec documents
+const testCacheControl = "max-age=60, public"
+
+// newTestRouter serves the API from a Handler of its own, so tests can use different drivers and settings
+func newTestRouter(driver Driver, opts ...HandlerOption) *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/concordances", GetConcordances).Methods("GET")
-	server = httptest.NewServer(r)
+	NewHandler(driver, append([]HandlerOption{WithCacheControl(testCacheControl)}, opts...)...).RegisterRoutes(r)
+	return r
+}
+
+func newTestServer(t *testing.T, driver Driver, opts ...HandlerOption) *httptest.Server {
+	s := httptest.NewServer(newTestRouter(driver, opts...))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func init() {
+	server = httptest.NewServer(newTestRouter(mockConcordanceDriver{}))
 	concordanceURL = fmt.Sprintf("%s/concordances", server.URL) //Grab the address for the API endpoint
 	isFound = true
 }
@@ -132,3 +164,208 @@ func TestCanNotRequestWithoutAuthorityOrConceptId(t *testing.T) {
 	assert.NoError(err)
 	assert.EqualValues(400, res.StatusCode)
 }
+
+func TestRequestIsTracedAsChildOfIncomingTraceContext(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer provider.Shutdown(context.Background())
+
+	req, _ := http.NewRequest("GET", concordanceURL+"?conceptId=bob", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	assert.Contains(spans, "GetConcordances")
+	assert.Contains(spans, "processParams")
+	assert.Contains(spans, "encodeResponse")
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans["GetConcordances"].SpanContext().TraceID().String())
+	assert.Equal("00f067aa0ba902b7", spans["GetConcordances"].Parent().SpanID().String())
+	assert.Equal(spans["GetConcordances"].SpanContext().SpanID(), spans["processParams"].Parent().SpanID())
+}
+
+func TestRequestIsLoggedWithStructuredFields(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	hook := test.NewLocal(logger.Logger())
+	defer hook.Reset()
+
+	req, _ := http.NewRequest("GET", concordanceURL+"?authority=some-authority&identifierValue=one&identifierValue=two", nil)
+	req.Header.Set("X-Request-Id", "tid_structuredlogging")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+	assert.Equal("tid_structuredlogging", res.Header.Get("X-Request-Id"))
+
+	assert.Len(hook.AllEntries(), 1, "each request is logged once")
+	entry := hook.LastEntry()
+	if assert.NotNil(entry) {
+		assert.Equal("Concordance request completed", entry.Message)
+		assert.Equal("tid_structuredlogging", entry.Data["transaction_id"])
+		assert.Equal("GET", entry.Data["method"])
+		assert.Equal("/concordances?authority=some-authority&identifierValue=one&identifierValue=two", entry.Data["uri"])
+		assert.Equal("authority", entry.Data["lookup_mode"])
+		assert.Equal("some-authority", entry.Data["authority"])
+		assert.Equal(2, entry.Data["input_count"])
+		assert.Equal(0, entry.Data["result_count"])
+		assert.Equal("success", entry.Data["outcome"])
+		assert.Contains(entry.Data, "latency_ms")
+	}
+}
+
+func TestResponseOrderAndETagAreStableRegardlessOfDriverOrder(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+
+	mockResult = Concordances{[]Concordance{concordedBrandTMEUPP, concordedBrandSmartlogic, concordedBrandTME}}
+	res1, err := http.Get(concordanceURL + "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad")
+	assert.NoError(err)
+	body1, _ := ioutil.ReadAll(res1.Body)
+
+	mockResult = Concordances{[]Concordance{concordedBrandTME, concordedBrandTMEUPP, concordedBrandSmartlogic}}
+	res2, err := http.Get(concordanceURL + "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad")
+	assert.NoError(err)
+	body2, _ := ioutil.ReadAll(res2.Body)
+
+	assert.Equal(string(body1), string(body2))
+	assert.NotEmpty(res1.Header.Get("ETag"))
+	assert.Equal(res1.Header.Get("ETag"), res2.Header.Get("ETag"))
+
+	var decoded Concordances
+	assert.NoError(json.Unmarshal(body1, &decoded))
+	assert.Equal([]Concordance{concordedBrandTME, concordedBrandSmartlogic, concordedBrandTMEUPP}, decoded.Concordance)
+}
+
+func TestConditionalGetReturnsNotModifiedForMatchingETag(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic}}
+
+	res, err := http.Get(concordanceURL + "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad")
+	assert.NoError(err)
+	etag := res.Header.Get("ETag")
+
+	req, _ := http.NewRequest("GET", concordanceURL+"?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad", nil)
+	req.Header.Set("If-None-Match", `"something-else", W/`+etag)
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.EqualValues(304, res.StatusCode)
+	assert.Equal(etag, res.Header.Get("ETag"))
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Empty(body)
+
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME}}
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+	assert.NotEqual(etag, res.Header.Get("ETag"))
+}
+
+func TestReturnBadRequestGivenUnknownSortOrder(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	res, err := http.Get(concordanceURL + "?conceptId=bob&sort=random")
+	assert.NoError(err)
+	assert.EqualValues(400, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), invalidSortOrder)
+}
+
+// fixedDriver answers every lookup with result and reports connErr from its connectivity check
+type fixedDriver struct {
+	result  Concordances
+	connErr error
+}
+
+func (d fixedDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	return copyConcordances(d.result), true, nil
+}
+
+func (d fixedDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	return copyConcordances(d.result), true, nil
+}
+
+func (d fixedDriver) CheckConnectivity() error {
+	return d.connErr
+}
+
+func TestHandlersWithDifferentDriversAndSettingsAreIndependent(t *testing.T) {
+	smartlogic := newTestServer(t, fixedDriver{result: Concordances{[]Concordance{concordedBrandSmartlogic}}},
+		WithCacheControl("max-age=10, public"), WithMaxIDsPerRequest(1))
+	tme := newTestServer(t, fixedDriver{result: Concordances{[]Concordance{concordedBrandTME}}})
+
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprintf("request %d", i), func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			res, err := http.Get(smartlogic.URL + "/concordances?conceptId=a")
+			assert.NoError(err)
+			assert.Equal("max-age=10, public", res.Header.Get("Cache-Control"))
+			assert.Equal([]string{concordedBrandSmartlogic.Identifier.Authority}, authoritiesIn(t, res))
+
+			res, err = http.Get(tme.URL + "/concordances?conceptId=a&conceptId=b")
+			assert.NoError(err)
+			assert.Equal(testCacheControl, res.Header.Get("Cache-Control"))
+			assert.Equal([]string{concordedBrandTME.Identifier.Authority}, authoritiesIn(t, res))
+
+			res, err = http.Get(smartlogic.URL + "/concordances?conceptId=a&conceptId=b")
+			assert.NoError(err)
+			assert.EqualValues(400, res.StatusCode)
+		})
+	}
+}
+
+func TestHandlerTimesRequestsWithItsClock(t *testing.T) {
+	assert := assert.New(t)
+	hook := test.NewLocal(logger.Logger())
+	defer hook.Reset()
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestServer(t, mockConcordanceDriver{}, WithClock(func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}))
+
+	res, err := http.Get(s.URL + "/concordances?conceptId=bob")
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+	if entry := hook.LastEntry(); assert.NotNil(entry) {
+		assert.Equal(250.0, entry.Data["latency_ms"])
+	}
+}
+
+func TestHandlerHealthChecksReportItsOwnDriver(t *testing.T) {
+	healthy := NewHandler(fixedDriver{})
+	failing := NewHandler(fixedDriver{connErr: errors.New("connection refused")})
+	defer healthy.StartAsyncChecker(10 * time.Millisecond)()
+	defer failing.StartAsyncChecker(10 * time.Millisecond)()
+
+	assert.Eventually(t, func() bool { return !failing.GTG().GoodToGo }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "connection refused", failing.GTG().Message)
+	assert.True(t, healthy.GTG().GoodToGo)
+	_, err := healthy.Checker()
+	assert.NoError(t, err)
+}
+
+func TestAsyncCheckerStopsChecking(t *testing.T) {
+	driver := &flakyDriver{}
+	h := NewHandler(driver)
+	stop := h.StartAsyncChecker(time.Millisecond)
+	stop()
+
+	driver.fail(errors.New("connection refused"))
+	time.Sleep(20 * time.Millisecond)
+	_, err := h.Checker()
+	assert.NoError(t, err, "the check isn't run again once stopped")
+}
@@ -0,0 +1,114 @@
+package concordances
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmcvetta/neoism"
+)
+
+// IndexChecker is implemented by drivers backed by a store whose query performance depends on schema indexes
+type IndexChecker interface {
+	CheckIndexes() error
+}
+
+type schemaIndex struct {
+	Label    string
+	Property string
+}
+
+func (i schemaIndex) String() string {
+	return fmt.Sprintf(":%s(%s)", i.Label, i.Property)
+}
+
+// requiredIndexes are the properties matched on by the concordance queries
+var requiredIndexes = []schemaIndex{
+	{Label: "Thing", Property: "uuid"},
+	{Label: "Thing", Property: "authority"},
+	{Label: "Thing", Property: "authorityValue"},
+	{Label: "Concept", Property: "leiCode"},
+	{Label: "Location", Property: "iso31661"},
+}
+
+// snapshotIndexes are the properties BuildSnapshot pages through concepts by
+var snapshotIndexes = []schemaIndex{
+	{Label: "Concept", Property: "prefUUID"},
+}
+
+type indexRow struct {
+	Label      string   `json:"label"`
+	Properties []string `json:"properties"`
+	State      string   `json:"state"`
+}
+
+// MissingIndexes lists the required indexes which don't exist or aren't online yet
+func (pcw CypherDriver) MissingIndexes() ([]schemaIndex, error) {
+	return pcw.missingIndexes(requiredIndexes)
+}
+
+func (pcw CypherDriver) missingIndexes(required []schemaIndex) ([]schemaIndex, error) {
+	var rows []indexRow
+	query := &neoism.CypherQuery{
+		Statement: `CALL db.indexes() YIELD label, properties, state RETURN label, properties, state`,
+		Result:    &rows,
+	}
+	if err := pcw.conn.CypherBatch([]*neoism.CypherQuery{query}); err != nil {
+		return nil, fmt.Errorf("failed to read Neo4j schema indexes: %v", err)
+	}
+
+	online := map[schemaIndex]bool{}
+	for _, row := range rows {
+		if len(row.Properties) == 1 && row.State == "ONLINE" {
+			online[schemaIndex{Label: row.Label, Property: row.Properties[0]}] = true
+		}
+	}
+
+	var missing []schemaIndex
+	for _, index := range required {
+		if !online[index] {
+			missing = append(missing, index)
+		}
+	}
+	return missing, nil
+}
+
+// CheckIndexes returns an error naming any required index that is missing or not online
+func (pcw CypherDriver) CheckIndexes() error {
+	return pcw.checkIndexes(requiredIndexes)
+}
+
+// CheckSnapshotIndexes returns an error naming any index BuildSnapshot relies on that is missing or not online.
+// They aren't required to serve concordances, so they are left out of CheckIndexes and CreateMissingIndexes.
+func (pcw CypherDriver) CheckSnapshotIndexes() error {
+	return pcw.checkIndexes(snapshotIndexes)
+}
+
+func (pcw CypherDriver) checkIndexes(required []schemaIndex) error {
+	missing, err := pcw.missingIndexes(required)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	names := make([]string, len(missing))
+	for i, index := range missing {
+		names[i] = index.String()
+	}
+	return fmt.Errorf("missing or offline Neo4j indexes: %s", strings.Join(names, ", "))
+}
+
+// CreateMissingIndexes creates any required index that doesn't already exist
+func (pcw CypherDriver) CreateMissingIndexes() error {
+	missing, err := pcw.MissingIndexes()
+	if err != nil {
+		return err
+	}
+	for _, index := range missing {
+		// EnsureIndexes takes a label to property map, so indexes sharing a label need separate calls
+		if err := pcw.conn.EnsureIndexes(map[string]string{index.Label: index.Property}); err != nil {
+			return fmt.Errorf("failed to create index %s: %v", index, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package concordances
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allRequiredIndexesOnline = []indexRow{
+	{Label: "Thing", Properties: []string{"uuid"}, State: "ONLINE"},
+	{Label: "Thing", Properties: []string{"authority"}, State: "ONLINE"},
+	{Label: "Thing", Properties: []string{"authorityValue"}, State: "ONLINE"},
+	{Label: "Concept", Properties: []string{"leiCode"}, State: "ONLINE"},
+	{Label: "Location", Properties: []string{"iso31661"}, State: "ONLINE"},
+	{Label: "Identifier", Properties: []string{"value"}, State: "ONLINE"},
+}
+
+func TestCheckIndexesPassesWhenAllRequiredIndexesAreOnline(t *testing.T) {
+	driver := NewCypherDriver(&fakeNeoConnection{indexes: allRequiredIndexesOnline}, "prod")
+	assert.NoError(t, driver.CheckIndexes())
+}
+
+func TestCheckIndexesReportsMissingAndOfflineIndexes(t *testing.T) {
+	assert := assert.New(t)
+	conn := &fakeNeoConnection{indexes: []indexRow{
+		{Label: "Thing", Properties: []string{"uuid"}, State: "ONLINE"},
+		{Label: "Thing", Properties: []string{"authorityValue"}, State: "POPULATING"},
+		{Label: "Concept", Properties: []string{"leiCode"}, State: "ONLINE"},
+	}}
+	driver := NewCypherDriver(conn, "prod")
+
+	err := driver.CheckIndexes()
+	assert.EqualError(err, "missing or offline Neo4j indexes: :Thing(authority), :Thing(authorityValue), :Location(iso31661)")
+}
+
+func TestCreateMissingIndexesOnlyCreatesAbsentIndexes(t *testing.T) {
+	assert := assert.New(t)
+	conn := &fakeNeoConnection{indexes: allRequiredIndexesOnline[:3]}
+	driver := NewCypherDriver(conn, "prod")
+
+	assert.NoError(driver.CreateMissingIndexes())
+	assert.Equal([]map[string]string{{"Concept": "leiCode"}, {"Location": "iso31661"}}, conn.ensuredIndexes)
+}
+
+func TestSnapshotIndexesAreCheckedSeparately(t *testing.T) {
+	driver := NewCypherDriver(&fakeNeoConnection{indexes: allRequiredIndexesOnline}, "prod")
+	assert.EqualError(t, driver.CheckSnapshotIndexes(), "missing or offline Neo4j indexes: :Concept(prefUUID)")
+
+	indexes := append([]indexRow{{Label: "Concept", Properties: []string{"prefUUID"}, State: "ONLINE"}}, allRequiredIndexesOnline...)
+	assert.NoError(t, NewCypherDriver(&fakeNeoConnection{indexes: indexes}, "prod").CheckSnapshotIndexes())
+}
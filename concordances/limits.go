@@ -0,0 +1,253 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/time/rate"
+)
+
+// ErrOverloaded is returned by a ConcurrencyLimitedDriver when a query can't start before its queue timeout
+var ErrOverloaded = errors.New("too many concurrent queries")
+
+// overloadRetryAfter is the Retry-After sent with 503s for shed queries
+const overloadRetryAfter = 1 * time.Second
+
+var (
+	rateLimitedRequests = metrics.GetOrRegisterCounter("concordances.ratelimit.rejected", metrics.DefaultRegistry)
+	shedQueries         = metrics.GetOrRegisterCounter("concordances.queries.shed", metrics.DefaultRegistry)
+	inFlightQueries     = metrics.GetOrRegisterGauge("concordances.queries.in_flight", metrics.DefaultRegistry)
+	queuedQueries       = metrics.GetOrRegisterGauge("concordances.queries.queued", metrics.DefaultRegistry)
+)
+
+// tooManyIDs reports whether a request for n IDs or values is over the handler's limit
+func (h *Handler) tooManyIDs(n int) bool {
+	return h.maxIDsPerRequest > 0 && n > h.maxIDsPerRequest
+}
+
+// RateLimiter holds a token bucket for each client, or for each IP address when requests aren't authenticated
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter allows each client requestsPerSecond on average, and bursts of up to burst requests.
+// A requestsPerSecond of 0 allows every request.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limit:     rate.Limit(requestsPerSecond),
+		burst:     burst,
+		idle:      10 * time.Minute,
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// SetLimit changes the limits of every client, including those that have already made requests
+func (l *RateLimiter) SetLimit(requestsPerSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = rate.Limit(requestsPerSecond)
+	l.burst = burst
+	now := time.Now()
+	for _, b := range l.buckets {
+		b.limiter.SetLimitAt(now, l.limit)
+		b.limiter.SetBurstAt(now, l.burst)
+	}
+}
+
+// reserve takes a token from key's bucket, returning how long to wait before retrying if there are none
+func (l *RateLimiter) reserve(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 {
+		return 0, true
+	}
+
+	// Forget buckets that have been idle long enough to have refilled, so one-off callers don't accumulate
+	if now.Sub(l.lastSweep) > l.idle {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > l.idle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return time.Second, false
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// RateLimitMiddleware responds 429 with a Retry-After header to clients which have used up their rate limit.
+// It must run after AuthMiddleware to limit by client rather than by IP address.
+func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := rateLimitKey(r)
+		retryAfter, ok := limiter.reserve(key, time.Now())
+		if !ok {
+			rateLimitedRequests.Inc(1)
+			log.WithTransactionID(transactionidutils.GetTransactionIDFromRequest(r)).
+				WithField("rate_limit_key", key).
+				Warn("Rejected rate limited request")
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "` + rateLimitExceeded + `"}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rateLimitKey(r *http.Request) string {
+	if client := clientFromContext(r.Context()); client != nil {
+		return "client:" + client.ID
+	}
+	// Behind the API gateway the connecting address is the gateway's, so use the caller's address the gateway appended.
+	// Earlier entries come from the caller, who could change them on every request to dodge the limit.
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		return "ip:" + strings.TrimSpace(hops[len(hops)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ConcurrencyLimitedDriver caps the number of queries a Driver runs at once. Queries over the cap wait in a queue
+// of limited length, and fail with ErrOverloaded if the queue is full or they can't start within the queue timeout,
+// so that a burst of expensive requests degrades into fast failures instead of saturating Neo4j.
+type ConcurrencyLimitedDriver struct {
+	driver       Driver
+	slots        chan struct{}
+	queue        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewConcurrencyLimitedDriver allows maxConcurrent queries to run at once, with up to maxQueued waiting for as
+// long as queueTimeout
+func NewConcurrencyLimitedDriver(driver Driver, maxConcurrent int, maxQueued int, queueTimeout time.Duration) *ConcurrencyLimitedDriver {
+	return &ConcurrencyLimitedDriver{
+		driver:       driver,
+		slots:        make(chan struct{}, maxConcurrent),
+		queue:        make(chan struct{}, maxQueued),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// ReadByConceptID runs the underlying driver's query once a slot is free
+func (d *ConcurrencyLimitedDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	release, err := d.acquire(ctx)
+	if err != nil {
+		return Concordances{}, false, err
+	}
+	defer release()
+	return d.driver.ReadByConceptID(ctx, ids)
+}
+
+// ReadByAuthority runs the underlying driver's query once a slot is free
+func (d *ConcurrencyLimitedDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	release, err := d.acquire(ctx)
+	if err != nil {
+		return Concordances{}, false, err
+	}
+	defer release()
+	return d.driver.ReadByAuthority(ctx, authority, ids)
+}
+
+// Unwrap returns the underlying driver
+func (d *ConcurrencyLimitedDriver) Unwrap() Driver {
+	return d.driver
+}
+
+// CheckConnectivity isn't limited, so healthchecks keep working under load
+func (d *ConcurrencyLimitedDriver) CheckConnectivity() error {
+	return d.driver.CheckConnectivity()
+}
+
+// CheckIndexes delegates to the underlying driver, if it can check indexes
+func (d *ConcurrencyLimitedDriver) CheckIndexes() error {
+	if checker, ok := d.driver.(IndexChecker); ok {
+		return checker.CheckIndexes()
+	}
+	return nil
+}
+
+func (d *ConcurrencyLimitedDriver) acquire(ctx context.Context) (func(), error) {
+	release := func() {
+		<-d.slots
+		inFlightQueries.Update(int64(len(d.slots)))
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+		inFlightQueries.Update(int64(len(d.slots)))
+		return release, nil
+	default:
+	}
+
+	select {
+	case d.queue <- struct{}{}:
+	default:
+		shedQueries.Inc(1)
+		return nil, ErrOverloaded
+	}
+	queuedQueries.Update(int64(len(d.queue)))
+	defer func() {
+		<-d.queue
+		queuedQueries.Update(int64(len(d.queue)))
+	}()
+
+	timer := time.NewTimer(d.queueTimeout)
+	defer timer.Stop()
+	select {
+	case d.slots <- struct{}{}:
+		inFlightQueries.Update(int64(len(d.slots)))
+		return release, nil
+	case <-timer.C:
+		shedQueries.Inc(1)
+		return nil, ErrOverloaded
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
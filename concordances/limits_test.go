@@ -0,0 +1,157 @@
+package concordances
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingDriver holds every query until release is closed
+type blockingDriver struct {
+	mockConcordanceDriver
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d blockingDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	d.started <- struct{}{}
+	<-d.release
+	return Concordances{}, false, nil
+}
+
+func TestRateLimiterIsPerClient(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewRateLimiter(1, 2)
+	now := time.Now()
+
+	_, ok := limiter.reserve("client:a", now)
+	assert.True(ok)
+	_, ok = limiter.reserve("client:a", now)
+	assert.True(ok)
+	retryAfter, ok := limiter.reserve("client:a", now)
+	assert.False(ok)
+	assert.True(retryAfter > 0 && retryAfter <= time.Second)
+
+	_, ok = limiter.reserve("client:b", now)
+	assert.True(ok, "other clients have their own bucket")
+	_, ok = limiter.reserve("client:a", now.Add(time.Second))
+	assert.True(ok, "the bucket refills over time")
+}
+
+func TestRateLimitMiddlewareRespondsTooManyRequests(t *testing.T) {
+	assert := assert.New(t)
+	handler := RateLimitMiddleware(NewRateLimiter(1, 1), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s := httptest.NewServer(withTestClient("batch-job", handler))
+	defer s.Close()
+
+	res, err := http.Get(s.URL)
+	require.NoError(t, err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	res, err = http.Get(s.URL)
+	require.NoError(t, err)
+	assert.Equal(http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal("1", res.Header.Get("Retry-After"))
+}
+
+func TestRateLimitKeyIsTheAddressTheGatewayAppended(t *testing.T) {
+	assert := assert.New(t)
+	r := httptest.NewRequest("GET", "/concordances", nil)
+	r.RemoteAddr = "10.0.0.1:51234"
+	assert.Equal("ip:10.0.0.1", rateLimitKey(r))
+
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9")
+	assert.Equal("ip:203.0.113.9", rateLimitKey(r), "callers can set the first entries themselves")
+
+	assert.Equal("client:batch-job", rateLimitKey(r.WithContext(withClient(r.Context(), &ClientIdentity{ID: "batch-job"}))))
+}
+
+func withTestClient(id string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), &ClientIdentity{ID: id})))
+	})
+}
+
+func TestConcurrencyLimitedDriverShedsQueriesOverItsQueue(t *testing.T) {
+	assert := assert.New(t)
+	blocking := blockingDriver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	driver := NewConcurrencyLimitedDriver(blocking, 1, 1, time.Hour)
+
+	running := make(chan error)
+	go func() {
+		_, _, err := driver.ReadByConceptID(context.Background(), []string{"running"})
+		running <- err
+	}()
+	<-blocking.started
+
+	queued := make(chan error)
+	go func() {
+		_, _, err := driver.ReadByConceptID(context.Background(), []string{"queued"})
+		queued <- err
+	}()
+	assert.Eventually(func() bool { return len(driver.queue) == 1 }, time.Second, time.Millisecond)
+
+	_, _, err := driver.ReadByConceptID(context.Background(), []string{"shed"})
+	assert.Equal(ErrOverloaded, err)
+
+	close(blocking.release)
+	assert.NoError(<-running)
+	<-blocking.started
+	assert.NoError(<-queued)
+}
+
+func TestConcurrencyLimitedDriverTimesOutQueuedQueries(t *testing.T) {
+	assert := assert.New(t)
+	blocking := blockingDriver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	driver := NewConcurrencyLimitedDriver(blocking, 1, 1, 10*time.Millisecond)
+	defer close(blocking.release)
+
+	go driver.ReadByConceptID(context.Background(), []string{"running"})
+	<-blocking.started
+
+	_, _, err := driver.ReadByConceptID(context.Background(), []string{"queued"})
+	assert.Equal(ErrOverloaded, err)
+}
+
+func TestOverloadedDriverRespondsServiceUnavailable(t *testing.T) {
+	assert := assert.New(t)
+	blocking := blockingDriver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	s := newTestServer(t, NewConcurrencyLimitedDriver(blocking, 1, 0, time.Hour))
+	running := make(chan struct{})
+	defer func() {
+		close(blocking.release)
+		<-running
+	}()
+
+	go func() {
+		http.Get(s.URL + "/concordances?conceptId=running")
+		close(running)
+	}()
+	<-blocking.started
+
+	res, err := http.Get(s.URL + "/concordances?conceptId=shed")
+	require.NoError(t, err)
+	assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal("1", res.Header.Get("Retry-After"))
+}
+
+func TestReturnBadRequestGivenTooManyIDs(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(t, mockConcordanceDriver{}, WithMaxIDsPerRequest(2))
+
+	res, err := http.Get(s.URL + "/concordances?conceptId=a&conceptId=b&conceptId=c")
+	require.NoError(t, err)
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), tooManyIDsInRequest)
+
+	res, err = http.Get(s.URL + "/concordances?authority=a&identifierValue=b&identifierValue=c")
+	require.NoError(t, err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,93 @@
+package concordances
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Responses with more concordances than this are only logged at the handler's debug payload sample rate
+const largePayloadConcordances = 50
+
+// requestLog accumulates the fields for the single structured line logged once a request completes
+type requestLog struct {
+	now    func() time.Time
+	start  time.Time
+	tid    string
+	fields map[string]interface{}
+}
+
+func newRequestLog(r *http.Request, now func() time.Time) *requestLog {
+	l := &requestLog{
+		now:   now,
+		start: now(),
+		tid:   transactionidutils.GetTransactionIDFromRequest(r),
+		fields: map[string]interface{}{
+			"method":      r.Method,
+			"uri":         r.RequestURI,
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+		},
+	}
+	if client := clientFromContext(r.Context()); client != nil {
+		l.fields["client_id"] = client.ID
+	}
+	return l
+}
+
+func (l *requestLog) set(key string, value interface{}) {
+	l.fields[key] = value
+}
+
+// finish logs the request outcome, at error level for server failures and info otherwise
+func (l *requestLog) finish(status int, found bool, err error) {
+	l.fields["status"] = status
+	l.fields["latency_ms"] = l.now().Sub(l.start).Seconds() * 1000
+
+	switch {
+	case status == http.StatusServiceUnavailable:
+		l.fields["outcome"] = "overloaded"
+	case status == http.StatusTooManyRequests:
+		l.fields["outcome"] = "rate_limited"
+	case status >= http.StatusInternalServerError:
+		l.fields["outcome"] = "error"
+	case status == http.StatusNotFound:
+		l.fields["outcome"] = "not_found"
+	case status == http.StatusForbidden:
+		l.fields["outcome"] = "forbidden"
+	case status >= http.StatusBadRequest:
+		l.fields["outcome"] = "bad_request"
+	case status == http.StatusNotModified:
+		l.fields["outcome"] = "not_modified"
+	case status == http.StatusMovedPermanently:
+		l.fields["outcome"] = "redirect"
+	case !found:
+		l.fields["outcome"] = "not_found"
+	default:
+		l.fields["outcome"] = "success"
+	}
+
+	entry := log.WithTransactionID(l.tid).WithFields(l.fields)
+	if err != nil {
+		entry.WithError(err).Error("Concordance request failed")
+		return
+	}
+	entry.Info("Concordance request completed")
+}
+
+// logPayload writes an already encoded response body to the debug log. Large bodies are sampled
+// so running at debug level doesn't cost as much as serving the request.
+func (h *Handler) logPayload(l *requestLog, resultCount int, body []byte) {
+	if !log.Logger().IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	if resultCount > largePayloadConcordances && h.payloadSampleRate > 1 &&
+		atomic.AddUint64(&h.payloadCounter, 1)%h.payloadSampleRate != 0 {
+		return
+	}
+	log.WithTransactionID(l.tid).WithField("result_count", resultCount).Debugf("Concordance response: %s", body)
+}
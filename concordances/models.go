@@ -9,6 +9,10 @@ type Concordances struct {
 type Concept struct {
 	ID     string `json:"id"`
 	APIURL string `json:"apiUrl"`
+	// RequestedIDs lists the IDs the caller asked for which resolved to this concept but aren't its canonical ID
+	RequestedIDs []string `json:"requestedIds,omitempty"`
+	// MergedFrom lists the requested IDs that used to be canonical before being merged into this concept
+	MergedFrom []string `json:"mergedFrom,omitempty"`
 }
 
 // Concordance is the structure used for the people API
@@ -21,12 +25,31 @@ type Concordance struct {
 type Identifier struct {
 	Authority       string `json:"authority"`
 	IdentifierValue string `json:"identifierValue"`
+	// Branches names the query branches which produced the identifier, and is only populated for debug requests
+	Branches []string `json:"branches,omitempty"`
 }
 
 type neoReadStruct struct {
-	CanonicalUUID  string   `json:"canonicalUUID"`
-	UUID           string   `json:"UUID"`
-	Types          []string `json:"types"`
-	Authority      string   `json:"authority"`
-	AuthorityValue string   `json:"authorityValue"`
+	CanonicalUUID      string   `json:"canonicalUUID"`
+	UUID               string   `json:"UUID"`
+	Types              []string `json:"types"`
+	Authority          string   `json:"authority"`
+	AuthorityValue     string   `json:"authorityValue"`
+	Branch             string   `json:"branch"`
+	RequestedUUID      string   `json:"requestedUUID"`
+	RequestedAuthority string   `json:"requestedAuthority"`
+}
+
+// GroupedConcordances lists each concept once with all of its identifiers, rather than repeating the concept per identifier
+type GroupedConcordances struct {
+	Concepts []ConceptIdentifiers `json:"concepts"`
+}
+
+// ConceptIdentifiers is a concept with every identifier it is concorded to
+type ConceptIdentifiers struct {
+	ID           string       `json:"id"`
+	APIURL       string       `json:"apiUrl"`
+	RequestedIDs []string     `json:"requestedIds,omitempty"`
+	MergedFrom   []string     `json:"mergedFrom,omitempty"`
+	Identifiers  []Identifier `json:"identifiers"`
 }
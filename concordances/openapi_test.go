@@ -0,0 +1,176 @@
+package concordances
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const apiSpecPath = "../api/api.yml"
+
+// newSpecRouter loads the OpenAPI document served at /__api, pointed at a test server
+func newSpecRouter(t *testing.T, serverURL string) routers.Router {
+	doc, err := openapi3.NewLoader().LoadFromFile(apiSpecPath)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	doc.Servers = openapi3.Servers{{URL: serverURL}}
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+	return router
+}
+
+// assertResponseMatchesSpec fails unless the status, headers and body of res are described by the spec
+func assertResponseMatchesSpec(t *testing.T, router routers.Router, res *http.Response) {
+	route, pathParams, err := router.FindRoute(res.Request)
+	if !assert.NoError(t, err, "%s is not in the spec", res.Request.URL) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    res.Request,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status:  res.StatusCode,
+		Header:  res.Header,
+		Body:    ioutil.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	assert.NoError(t, err, "%s responded %d with %s", res.Request.URL, res.StatusCode, body)
+}
+
+func TestGetConcordancesResponsesMatchAPISpec(t *testing.T) {
+	router := newSpecRouter(t, server.URL)
+	defer func() {
+		mockResult = Concordances{}
+		isFound = true
+	}()
+
+	tests := []struct {
+		name   string
+		query  string
+		result Concordances
+		found  bool
+		status int
+	}{
+		{"byConceptID", "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad", Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME}}, true, 200},
+		{"byAuthority", "?authority=http://api.ft.com/system/FT-TME&identifierValue=VGhlIFJvbWFu-QnJhbmRz", Concordances{[]Concordance{concordedBrandTME}}, true, 200},
+		{"grouped", "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad&format=grouped", Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME}}, true, 200},
+		{"notFound", "?conceptId=unknown", Concordances{}, false, 200},
+		{"notFoundGrouped", "?conceptId=unknown&format=grouped", Concordances{}, false, 200},
+		{"multipleAuthorities", "?authority=a&authority=b&identifierValue=c", Concordances{}, true, 400},
+		{"conceptAndAuthority", "?conceptId=a&authority=b", Concordances{}, true, 400},
+		{"neitherConceptNorAuthority", "?identifierValue=a", Concordances{}, true, 400},
+		{"unknownSort", "?conceptId=a&sort=random", Concordances{}, true, 400},
+		{"unknownFormat", "?conceptId=a&format=xml", Concordances{}, true, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockResult = test.result
+			isFound = test.found
+			res, err := http.Get(concordanceURL + test.query)
+			require.NoError(t, err)
+			assert.Equal(t, test.status, res.StatusCode)
+			assertResponseMatchesSpec(t, router, res)
+		})
+	}
+}
+
+func TestConditionalGetResponseMatchesAPISpec(t *testing.T) {
+	router := newSpecRouter(t, server.URL)
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic}}
+
+	res, err := http.Get(concordanceURL + "?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad")
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", concordanceURL+"?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad", nil)
+	req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, 304, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+}
+
+func TestRESTResponsesMatchAPISpec(t *testing.T) {
+	s, done := newRESTServer(leafConcordanceDriver{})
+	defer done()
+	router := newSpecRouter(t, s.URL)
+
+	for _, path := range []string{
+		"/concepts/b20801ac-5a76-43cf-b816-8c3b2f7133ad/concordances",
+		"/concepts/b20801ac-5a76-43cf-b816-8c3b2f7133ad/concordances?format=grouped",
+		"/concepts/0b3ea5e3-5a8d-4c4a-8e6e-7f1b2b2b2b2b/concordances",
+		"/authorities/SMARTLOGIC/identifiers/b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+		"/authorities/SMARTLOGIC/identifiers/unknown",
+	} {
+		res, err := noRedirectClient.Get(s.URL + path)
+		require.NoError(t, err)
+		assertResponseMatchesSpec(t, router, res)
+	}
+}
+
+func TestAuthResponsesMatchAPISpec(t *testing.T) {
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	s := newAuthServer(t, NewAPIKeyAuthenticator(clients))
+	router := newSpecRouter(t, s.URL)
+
+	res := getWithHeader(t, s.URL+"/concordances?conceptId=a", "", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+
+	res = getWithHeader(t, s.URL+"/concordances?authority=http://api.ft.com/system/SMARTLOGIC&identifierValue=x", "X-Api-Key", "partner-key")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+
+	res = getWithHeader(t, s.URL+"/authorities/SMARTLOGIC/identifiers/x", "X-Api-Key", "partner-key")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+}
+
+func TestLimitResponsesMatchAPISpec(t *testing.T) {
+	s := newTestServer(t, mockConcordanceDriver{}, WithMaxIDsPerRequest(1))
+	router := newSpecRouter(t, s.URL)
+
+	res, err := http.Get(s.URL + "/concordances?conceptId=a&conceptId=b")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+
+	blocking := blockingDriver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	s = newTestServer(t, NewConcurrencyLimitedDriver(blocking, 1, 0, time.Hour))
+	router = newSpecRouter(t, s.URL)
+	running := make(chan struct{})
+	defer func() {
+		close(blocking.release)
+		<-running
+	}()
+	go func() {
+		http.Get(s.URL + "/concordances?conceptId=running")
+		close(running)
+	}()
+	<-blocking.started
+
+	res, err = http.Get(s.URL + "/concordances?conceptId=shed")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	assertResponseMatchesSpec(t, router, res)
+}
@@ -0,0 +1,48 @@
+package concordances
+
+import "sort"
+
+// SortOrder names the field concordances are primarily ordered by. Ties are broken by the remaining
+// fields in the default order: concept ID, authority, identifier value.
+type SortOrder string
+
+const (
+	SortByConceptID       SortOrder = "conceptId"
+	SortByAuthority       SortOrder = "authority"
+	SortByIdentifierValue SortOrder = "identifierValue"
+
+	DefaultSortOrder = SortByConceptID
+)
+
+// ParseSortOrder validates the sort request parameter, defaulting to DefaultSortOrder when it is empty
+func ParseSortOrder(s string) (SortOrder, bool) {
+	switch SortOrder(s) {
+	case "":
+		return DefaultSortOrder, true
+	case SortByConceptID, SortByAuthority, SortByIdentifierValue:
+		return SortOrder(s), true
+	}
+	return "", false
+}
+
+// SortConcordances orders concordances in place so the same data always serialises to the same bytes
+func SortConcordances(c []Concordance, by SortOrder) {
+	keys := func(con Concordance) [3]string {
+		switch by {
+		case SortByAuthority:
+			return [3]string{con.Identifier.Authority, con.Concept.ID, con.Identifier.IdentifierValue}
+		case SortByIdentifierValue:
+			return [3]string{con.Identifier.IdentifierValue, con.Concept.ID, con.Identifier.Authority}
+		}
+		return [3]string{con.Concept.ID, con.Identifier.Authority, con.Identifier.IdentifierValue}
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		ki, kj := keys(c[i]), keys(c[j])
+		for k := range ki {
+			if ki[k] != kj[k] {
+				return ki[k] < kj[k]
+			}
+		}
+		return false
+	})
+}
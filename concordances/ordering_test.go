@@ -0,0 +1,53 @@
+package concordances
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCypherResultsAreConvertedInDefaultOrder(t *testing.T) {
+	rows := []neoReadStruct{
+		{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "UPP", AuthorityValue: "d56e7388-25cb-343e-aea9-8b512e28476e"},
+		{CanonicalUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Types: []string{"Thing", "Concept", "Brand"}, Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz"},
+		{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "FACTSET", AuthorityValue: "7IV872-E"},
+		{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "UPP", AuthorityValue: "2cdeb859-70df-3a0e-b125-f958366bea44"},
+	}
+
+	actual := neoReadStructToConcordances(rows, "prod", authorityMap, false).Concordance
+
+	values := make([]string, len(actual))
+	for i, c := range actual {
+		values[i] = c.Identifier.IdentifierValue
+	}
+	assert.Equal(t, []string{"VGhlIFJvbWFu-QnJhbmRz", "7IV872-E", "2cdeb859-70df-3a0e-b125-f958366bea44", "d56e7388-25cb-343e-aea9-8b512e28476e"}, values)
+}
+
+func TestSortConcordancesBySelectedOrder(t *testing.T) {
+	assert := assert.New(t)
+	input := []Concordance{concordedBrandTMEUPP, unconcordedBrandTME, concordedBrandSmartlogic, concordedBrandTME}
+
+	byAuthority := append([]Concordance{}, input...)
+	SortConcordances(byAuthority, SortByAuthority)
+	assert.Equal([]Concordance{unconcordedBrandTME, concordedBrandTME, concordedBrandSmartlogic, concordedBrandTMEUPP}, byAuthority)
+
+	byValue := append([]Concordance{}, input...)
+	SortConcordances(byValue, SortByIdentifierValue)
+	assert.Equal([]Concordance{concordedBrandTMEUPP, unconcordedBrandTME, concordedBrandTME, concordedBrandSmartlogic}, byValue)
+}
+
+func TestParseSortOrder(t *testing.T) {
+	assert := assert.New(t)
+	for in, expected := range map[string]SortOrder{
+		"":                DefaultSortOrder,
+		"conceptId":       SortByConceptID,
+		"authority":       SortByAuthority,
+		"identifierValue": SortByIdentifierValue,
+	} {
+		actual, ok := ParseSortOrder(in)
+		assert.True(ok, in)
+		assert.Equal(expected, actual, in)
+	}
+	_, ok := ParseSortOrder("uuid")
+	assert.False(ok)
+}
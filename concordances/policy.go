@@ -0,0 +1,200 @@
+package concordances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/rcrowley/go-metrics"
+)
+
+var policyReloadFailures = metrics.GetOrRegisterCounter("concordances.policy.reload_failures", metrics.DefaultRegistry)
+
+// VisibilityPolicy restricts licensed authorities to the callers entitled to them. It is loaded from a JSON file:
+//
+//	{
+//	  "restrictedAuthorities": {"FACTSET": ["factset"], "LEI": ["lei"]},
+//	  "clientEntitlements": {"search-indexing": ["factset", "lei"]},
+//	  "entitlementsHeader": "X-FT-Entitlements"
+//	}
+//
+// Identifiers in a restricted authority are only returned to callers with at least one of its entitlements, and
+// only those callers may look identifiers up in it. Authorities are named as in authorityMap, or by URI.
+// Authenticated clients get the entitlements listed for their ID, and if entitlementsHeader is set, any in that
+// comma separated header of REST requests. Only set it when the API gateway overwrites the header on every request.
+type VisibilityPolicy struct {
+	restricted         map[string][]string
+	clientEntitlements map[string][]string
+	entitlementsHeader string
+}
+
+type policyFile struct {
+	RestrictedAuthorities map[string][]string `json:"restrictedAuthorities"`
+	ClientEntitlements    map[string][]string `json:"clientEntitlements"`
+	EntitlementsHeader    string              `json:"entitlementsHeader"`
+}
+
+// LoadVisibilityPolicy reads the policy file at path
+func LoadVisibilityPolicy(path string) (*VisibilityPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing policy file %s: %v", path, err)
+	}
+
+	policy := &VisibilityPolicy{
+		restricted:         map[string][]string{},
+		clientEntitlements: file.ClientEntitlements,
+		entitlementsHeader: file.EntitlementsHeader,
+	}
+	for authority, entitlements := range file.RestrictedAuthorities {
+		uri := authority
+		if !strings.HasPrefix(authority, authorityURIPrefix) {
+			var found bool
+			if uri, found = AuthorityToURI(authority); !found {
+				return nil, fmt.Errorf("unknown authority %s in policy file %s", authority, path)
+			}
+		}
+		if len(entitlements) == 0 {
+			return nil, fmt.Errorf("authority %s in policy file %s has no entitlements, so nobody could see it", authority, path)
+		}
+		policy.restricted[uri] = entitlements
+	}
+	return policy, nil
+}
+
+// viewerFor works out the entitlements of the caller making a request. header is nil if the request's headers can't
+// be trusted to carry entitlements.
+func (p *VisibilityPolicy) viewerFor(client *ClientIdentity, header func(string) string) *viewer {
+	v := &viewer{policy: p, entitlements: map[string]bool{}}
+	if client != nil {
+		for _, e := range p.clientEntitlements[client.ID] {
+			v.entitlements[e] = true
+		}
+	}
+	if p.entitlementsHeader != "" && header != nil {
+		for _, e := range strings.Split(header(p.entitlementsHeader), ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				v.entitlements[e] = true
+			}
+		}
+	}
+	return v
+}
+
+// viewer is a caller's view of the authorities under a policy
+type viewer struct {
+	policy       *VisibilityPolicy
+	entitlements map[string]bool
+}
+
+func (v *viewer) canSee(authority string) bool {
+	if v == nil {
+		return true
+	}
+	required, restricted := v.policy.restricted[authority]
+	if !restricted {
+		return true
+	}
+	for _, e := range required {
+		if v.entitlements[e] {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyStore holds the current VisibilityPolicy, reloading it when its file changes
+type PolicyStore struct {
+	path   string
+	policy atomic.Value
+}
+
+// NewPolicyStore loads the policy file at path
+func NewPolicyStore(path string) (*PolicyStore, error) {
+	policy, err := LoadVisibilityPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &PolicyStore{path: path}
+	s.policy.Store(policy)
+	return s, nil
+}
+
+// Policy returns the current policy
+func (s *PolicyStore) Policy() *VisibilityPolicy {
+	return s.policy.Load().(*VisibilityPolicy)
+}
+
+// Reload replaces the current policy with the contents of the file. If the file is invalid, the current policy is
+// kept, so a bad edit can't open up restricted authorities.
+func (s *PolicyStore) Reload() error {
+	policy, err := LoadVisibilityPolicy(s.path)
+	if err != nil {
+		policyReloadFailures.Inc(1)
+		return err
+	}
+	s.policy.Store(policy)
+	return nil
+}
+
+// Watch reloads the policy whenever the file changes or the process receives a SIGHUP, checking every interval
+// until stopped
+func (s *PolicyStore) Watch(interval time.Duration) (stop func()) {
+	return watchFile(s.path, interval, func() {
+		if err := s.Reload(); err != nil {
+			log.WithError(err).Error("Failed to reload visibility policy, keeping the previous one")
+			return
+		}
+		log.WithField("path", s.path).Info("Reloaded visibility policy")
+	})
+}
+
+// PolicyMiddleware applies the current visibility policy to each request. It must run after AuthMiddleware for
+// client entitlements to apply.
+func PolicyMiddleware(store *PolicyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := store.Policy().viewerFor(clientFromContext(r.Context()), r.Header.Get)
+		next.ServeHTTP(w, r.WithContext(withViewer(r.Context(), v)))
+	})
+}
+
+type viewerKey struct{}
+
+func withViewer(ctx context.Context, v *viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, v)
+}
+
+func viewerFromContext(ctx context.Context) *viewer {
+	v, _ := ctx.Value(viewerKey{}).(*viewer)
+	return v
+}
+
+// canSee reports whether the caller may see identifiers in the authority, under both its client's allowed
+// authorities and the visibility policy
+func canSee(ctx context.Context, authority string) bool {
+	return clientFromContext(ctx).CanSee(authority) && viewerFromContext(ctx).canSee(authority)
+}
+
+// filterVisible drops the identifiers in authorities the caller can't see
+func filterVisible(ctx context.Context, c Concordances) Concordances {
+	if clientFromContext(ctx) == nil && viewerFromContext(ctx) == nil {
+		return c
+	}
+	filtered := Concordances{}
+	for _, con := range c.Concordance {
+		if canSee(ctx, con.Identifier.Authority) {
+			filtered.Concordance = append(filtered.Concordance, con)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,115 @@
+package concordances
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicyFile = `{
+	"restrictedAuthorities": {"Smartlogic": ["smartlogic-licence"], "http://api.ft.com/system/UPP": ["upp-licence", "everything"]},
+	"clientEntitlements": {"search-indexing": ["smartlogic-licence"]},
+	"entitlementsHeader": "X-FT-Entitlements"
+}`
+
+func newPolicyServer(t *testing.T, store *PolicyStore) *httptest.Server {
+	clients, err := LoadClients(writeTestFile(t, "clients.json", testClientsFile))
+	require.NoError(t, err)
+	s := httptest.NewServer(AuthMiddleware(NewAPIKeyAuthenticator(clients), PolicyMiddleware(store, newTestRouter(mockConcordanceDriver{}))))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func authoritiesIn(t *testing.T, res *http.Response) []string {
+	var c Concordances
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&c))
+	authorities := []string{}
+	for _, con := range c.Concordance {
+		authorities = append(authorities, con.Identifier.Authority)
+	}
+	return authorities
+}
+
+func TestLoadVisibilityPolicyRejectsInvalidFiles(t *testing.T) {
+	_, err := LoadVisibilityPolicy(writeTestFile(t, "policy.json", `{"restrictedAuthorities": {"NOT-AN-AUTHORITY": ["x"]}}`))
+	assert.Error(t, err)
+	_, err = LoadVisibilityPolicy(writeTestFile(t, "policy.json", `{"restrictedAuthorities": {"FACTSET": []}}`))
+	assert.Error(t, err)
+}
+
+func TestPolicyFiltersRestrictedAuthorities(t *testing.T) {
+	assert := assert.New(t)
+	isFound = true
+	defer func() { mockResult = Concordances{} }()
+	mockResult = Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME, concordedBrandTMEUPP}}
+
+	store, err := NewPolicyStore(writeTestFile(t, "policy.json", testPolicyFile))
+	require.NoError(t, err)
+	s := newPolicyServer(t, store)
+	url := s.URL + "/concordances?conceptId=b20801ac-5a76-43cf-b816-8c3b2f7133ad"
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Api-Key", "search-key")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal([]string{"http://api.ft.com/system/FT-TME", "http://api.ft.com/system/SMARTLOGIC"}, authoritiesIn(t, res))
+
+	req.Header.Set("X-FT-Entitlements", "other, everything")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal("max-age=60, private", res.Header.Get("Cache-Control"))
+	assert.Equal([]string{"http://api.ft.com/system/FT-TME", "http://api.ft.com/system/SMARTLOGIC", "http://api.ft.com/system/UPP"}, authoritiesIn(t, res))
+
+	req, _ = http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Api-Key", "partner-key")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal([]string{"http://api.ft.com/system/FT-TME"}, authoritiesIn(t, res), "client allowed authorities still apply")
+}
+
+func TestPolicyRejectsLookupsInRestrictedAuthorities(t *testing.T) {
+	assert := assert.New(t)
+	store, err := NewPolicyStore(writeTestFile(t, "policy.json", testPolicyFile))
+	require.NoError(t, err)
+	s := newPolicyServer(t, store)
+
+	for path, status := range map[string]int{
+		"/authorities/UPP/identifiers/x":                                                http.StatusForbidden,
+		"/concordances?authority=http://api.ft.com/system/UPP&identifierValue=x":        http.StatusForbidden,
+		"/concordances?authority=http://api.ft.com/system/SMARTLOGIC&identifierValue=x": http.StatusOK,
+	} {
+		req, _ := http.NewRequest("GET", s.URL+path, nil)
+		req.Header.Set("X-Api-Key", "search-key")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(status, res.StatusCode, path)
+	}
+}
+
+func TestPolicyStoreReloadsChangedFile(t *testing.T) {
+	assert := assert.New(t)
+	path := writeTestFile(t, "policy.json", testPolicyFile)
+	store, err := NewPolicyStore(path)
+	require.NoError(t, err)
+	stop := store.Watch(5 * time.Millisecond)
+	assert.False(store.Policy().viewerFor(nil, http.Header{}.Get).canSee("http://api.ft.com/system/UPP"))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"restrictedAuthorities": {"FACTSET": ["factset"]}}`), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Eventually(func() bool {
+		return store.Policy().viewerFor(nil, http.Header{}.Get).canSee("http://api.ft.com/system/UPP")
+	}, time.Second, 5*time.Millisecond)
+
+	stop()
+	require.NoError(t, ioutil.WriteFile(path, []byte(`not json`), 0600))
+	assert.Error(store.Reload())
+	assert.False(store.Policy().viewerFor(nil, http.Header{}.Get).canSee("http://api.ft.com/system/FACTSET"), "an invalid file keeps the previous policy")
+}
@@ -0,0 +1,107 @@
+package concordances
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const authorityURIPrefix = "http://api.ft.com/system/"
+
+// GetConceptConcordances serves GET /concepts/{uuid}/concordances. Requests for a UUID which has been
+// concorded into another concept are redirected to the canonical concept's URL, unless redirect=false is given.
+func (h *Handler) GetConceptConcordances(w http.ResponseWriter, r *http.Request) {
+	ctx, span, reqLog := h.startRequest(w, r, "GetConceptConcordances")
+	defer span.End()
+
+	uuid := mux.Vars(r)["uuid"]
+	span.SetAttributes(attribute.String("concordances.concept_id", uuid))
+	reqLog.set("lookup_mode", "concept")
+	reqLog.set("input_count", 1)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	opts, msg := parseResponseOptions(r.URL.Query())
+	if msg != "" {
+		writeMessage(w, reqLog, http.StatusBadRequest, msg, nil)
+		return
+	}
+	if opts.debug {
+		ctx = withDebug(ctx)
+	}
+
+	concordance, found, err := h.driver.ReadByConceptID(ctx, []string{uuid})
+	setStaleHeader(ctx, w)
+	if err != nil {
+		endSpan(span, err)
+		writeDriverError(w, reqLog, err)
+		return
+	}
+	if !found {
+		writeMessage(w, reqLog, http.StatusNotFound, conceptNotFound, nil)
+		return
+	}
+
+	redirect, err := strconv.ParseBool(r.URL.Query().Get("redirect"))
+	if err != nil {
+		redirect = true
+	}
+	if canonical := strings.TrimPrefix(concordance.Concordance[0].Concept.ID, thingURIPrefix); redirect && canonical != uuid {
+		location := "/concepts/" + canonical + "/concordances"
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		w.Header().Set("Location", location)
+		w.Header().Set("Cache-Control", h.cacheControlFor(ctx))
+		w.WriteHeader(http.StatusMovedPermanently)
+		reqLog.finish(http.StatusMovedPermanently, true, nil)
+		return
+	}
+
+	h.writeConcordances(ctx, w, r, reqLog, concordance, found, opts)
+}
+
+// GetIdentifierConcordances serves GET /authorities/{authority}/identifiers/{value}, where authority is the
+// last segment of the authority URI, e.g. FT-TME for http://api.ft.com/system/FT-TME
+func (h *Handler) GetIdentifierConcordances(w http.ResponseWriter, r *http.Request) {
+	ctx, span, reqLog := h.startRequest(w, r, "GetIdentifierConcordances")
+	defer span.End()
+
+	vars := mux.Vars(r)
+	authority := authorityURIPrefix + vars["authority"]
+	span.SetAttributes(attribute.String("concordances.authority", authority))
+	reqLog.set("lookup_mode", "identifier")
+	reqLog.set("authority", authority)
+	reqLog.set("input_count", 1)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	opts, msg := parseResponseOptions(r.URL.Query())
+	if msg != "" {
+		writeMessage(w, reqLog, http.StatusBadRequest, msg, nil)
+		return
+	}
+	if opts.debug {
+		ctx = withDebug(ctx)
+	}
+
+	if !canSee(ctx, authority) {
+		writeMessage(w, reqLog, http.StatusForbidden, authorityNotPermitted, nil)
+		return
+	}
+
+	concordance, found, err := h.driver.ReadByAuthority(ctx, authority, []string{vars["value"]})
+	setStaleHeader(ctx, w)
+	if err != nil {
+		endSpan(span, err)
+		writeDriverError(w, reqLog, err)
+		return
+	}
+	if !found {
+		writeMessage(w, reqLog, http.StatusNotFound, identifierNotFound, nil)
+		return
+	}
+
+	h.writeConcordances(ctx, w, r, reqLog, concordance, found, opts)
+}
@@ -0,0 +1,99 @@
+package concordances
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// leafConcordanceDriver resolves any UUID to the concorded Brand b20801ac and only knows about the Smartlogic identifier
+type leafConcordanceDriver struct {
+	mockConcordanceDriver
+}
+
+func (driver leafConcordanceDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	return Concordances{[]Concordance{concordedBrandSmartlogic, concordedBrandTME}}, true, nil
+}
+
+func (driver leafConcordanceDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	if authority == concordedBrandSmartlogic.Identifier.Authority && ids[0] == concordedBrandSmartlogic.Identifier.IdentifierValue {
+		return Concordances{[]Concordance{concordedBrandSmartlogic}}, true, nil
+	}
+	return Concordances{}, false, nil
+}
+
+func newRESTServer(driver Driver) (*httptest.Server, func()) {
+	s := httptest.NewServer(newTestRouter(driver))
+	return s, s.Close
+}
+
+var noRedirectClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+func TestConceptConcordancesForCanonicalUUID(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newRESTServer(leafConcordanceDriver{})
+	defer done()
+
+	res, err := noRedirectClient.Get(s.URL + "/concepts/b20801ac-5a76-43cf-b816-8c3b2f7133ad/concordances")
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+	assert.NotEmpty(res.Header.Get("ETag"))
+}
+
+func TestConceptConcordancesRedirectsLeafUUIDToCanonical(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newRESTServer(leafConcordanceDriver{})
+	defer done()
+
+	res, err := noRedirectClient.Get(s.URL + "/concepts/70f4732b-7f7d-30a1-9c29-0cceec23760e/concordances?format=grouped")
+	assert.NoError(err)
+	assert.EqualValues(301, res.StatusCode)
+	assert.Equal("/concepts/b20801ac-5a76-43cf-b816-8c3b2f7133ad/concordances?format=grouped", res.Header.Get("Location"))
+}
+
+func TestConceptConcordancesNotFound(t *testing.T) {
+	assert := assert.New(t)
+	isFound = false
+	defer func() { isFound = true }()
+	s, done := newRESTServer(mockConcordanceDriver{})
+	defer done()
+
+	res, err := noRedirectClient.Get(s.URL + "/concepts/6f14ea94-690f-3ed4-98c7-b926683c735a/concordances")
+	assert.NoError(err)
+	assert.EqualValues(404, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), conceptNotFound)
+}
+
+func TestIdentifierConcordances(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newRESTServer(leafConcordanceDriver{})
+	defer done()
+
+	res, err := noRedirectClient.Get(fmt.Sprintf("%s/authorities/SMARTLOGIC/identifiers/%s", s.URL, concordedBrandSmartlogic.Identifier.IdentifierValue))
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+
+	res, err = noRedirectClient.Get(s.URL + "/authorities/FT-TME/identifiers/unknown")
+	assert.NoError(err)
+	assert.EqualValues(404, res.StatusCode)
+	msg, _ := ioutil.ReadAll(res.Body)
+	assert.Contains(string(msg), identifierNotFound)
+}
+
+func TestConceptConcordancesForLeafUUIDWithoutRedirect(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newRESTServer(leafConcordanceDriver{})
+	defer done()
+
+	res, err := noRedirectClient.Get(s.URL + "/concepts/70f4732b-7f7d-30a1-9c29-0cceec23760e/concordances?redirect=false")
+	assert.NoError(err)
+	assert.EqualValues(200, res.StatusCode)
+}
@@ -0,0 +1,165 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/jmcvetta/neoism"
+	"github.com/rcrowley/go-metrics"
+)
+
+var (
+	queryRetries        = metrics.GetOrRegisterCounter("concordances.neo4j.retries", metrics.DefaultRegistry)
+	queryRetryExhausted = metrics.GetOrRegisterCounter("concordances.neo4j.retries_exhausted", metrics.DefaultRegistry)
+	queryAttempts       = metrics.GetOrRegisterHistogram("concordances.neo4j.attempts", metrics.DefaultRegistry, metrics.NewUniformSample(1028))
+)
+
+// RetryPolicy retries Cypher queries which fail with transient errors, such as a dropped connection or a Neo4j
+// TransientError. The zero value doesn't retry.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a query is run, including the first
+	MaxAttempts int
+	// InitialBackoff is the longest wait before the first retry. The limit doubles for each retry after that, and
+	// the actual wait is a random duration up to the limit, so that retries from many requests are spread out.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts
+	MaxBackoff time.Duration
+}
+
+// WithRetryPolicy retries queries which fail with transient errors, as long as the request's deadline allows
+func WithRetryPolicy(policy RetryPolicy) CypherDriverOption {
+	return func(d *CypherDriver) {
+		d.retry = policy
+	}
+}
+
+// backoff is the random wait before the given retry, counting from 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.InitialBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || limit < p.MaxBackoff); i++ {
+		limit *= 2
+	}
+	if p.MaxBackoff > 0 && limit > p.MaxBackoff {
+		limit = p.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+// runWithRetries runs attempt until it succeeds, fails with an error which isn't transient, or the policy or the
+// context's deadline leave no time for another attempt
+func (pcw CypherDriver) runWithRetries(ctx context.Context, statementName string, query *neoism.CypherQuery, attempt func() error) (retries int, err error) {
+	defer func() { queryAttempts.Update(int64(retries + 1)) }()
+	for {
+		err = attempt()
+		if err == nil || !isTransient(err) || retries+1 >= pcw.retry.MaxAttempts {
+			if err != nil && retries > 0 {
+				queryRetryExhausted.Inc(1)
+			}
+			return retries, err
+		}
+
+		wait := pcw.retry.backoff(retries + 1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			queryRetryExhausted.Inc(1)
+			return retries, err
+		}
+		log.WithError(err).WithField("statement_name", statementName).Warn("Retrying Cypher query after a transient error")
+		if sleepErr := pcw.sleep(ctx, wait); sleepErr != nil {
+			queryRetryExhausted.Inc(1)
+			return retries, err
+		}
+		resetResult(query)
+		retries++
+		queryRetries.Inc(1)
+	}
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resetResult empties a query's Result slice, so rows from a failed attempt aren't returned with the next one's
+func resetResult(query *neoism.CypherQuery) {
+	v := reflect.ValueOf(query.Result)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	v.Elem().Set(reflect.Zero(v.Elem().Type()))
+}
+
+// transientMessages are found in the errors of failures which are likely to succeed if retried
+var transientMessages = []string{
+	"Neo.TransientError.",
+	"connection reset by peer",
+	"broken pipe",
+	"503 Service Unavailable",
+}
+
+// isTransient classifies errors from Neo4j which are worth retrying. Errors in the query itself, or from Neo4j
+// rejecting it, would only fail again.
+func isTransient(err error) bool {
+	// A query which ran out of time, or whose caller has gone away, shouldn't be run again. Neither should one which
+	// timed out for any other reason, as REST requests have no deadline to stop the retries piling onto a slow Neo4j.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		// neoism returns some errors by value and some by pointer
+		var transient bool
+		switch neoErr := e.(type) {
+		case neoism.NeoError:
+			transient = strings.Contains(neoErr.Exception, "TransientError")
+		case *neoism.NeoError:
+			transient = strings.Contains(neoErr.Exception, "TransientError")
+		case neoism.TxQueryError:
+			transient = hasTransientCode(neoErr.Errors)
+		case *neoism.TxQueryError:
+			transient = hasTransientCode(neoErr.Errors)
+		}
+		if transient || hasTransientMessage(e.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTransientMessage(msg string) bool {
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTransientCode(errs []neoism.TxError) bool {
+	for _, e := range errs {
+		if strings.HasPrefix(e.Code, "Neo.TransientError.") {
+			return true
+		}
+	}
+	return false
+}
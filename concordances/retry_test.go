@@ -0,0 +1,169 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreliableNeoConnection fails with each of errs in turn before answering like a fakeNeoConnection
+type unreliableNeoConnection struct {
+	fakeNeoConnection
+	errs  []error
+	calls int
+}
+
+func (c *unreliableNeoConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		// A failed batch can leave partial results behind
+		*queries[0].Result.(*[]neoReadStruct) = append([]neoReadStruct{}, c.rows...)
+		return err
+	}
+	return c.fakeNeoConnection.CypherBatch(queries)
+}
+
+var (
+	errConnectionReset = &url.Error{Op: "Post", URL: "http://localhost:7474/db/data/batch", Err: syscall.ECONNRESET}
+	errNeoTransient    = neoism.NeoError{Message: "deadlock", Exception: "Neo.TransientError.Transaction.DeadlockDetected"}
+	errNeoSyntax       = neoism.NeoError{Message: "invalid input", Exception: "SyntaxException"}
+)
+
+// timeoutError is the net.Error of a request which timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// retryingDriver retries on conn, recording the waits between attempts instead of sleeping
+func retryingDriver(conn *unreliableNeoConnection, policy RetryPolicy, waits *[]time.Duration) CypherDriver {
+	driver := NewCypherDriver(conn, "prod", WithRetryPolicy(policy))
+	driver.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return driver
+}
+
+func testQuery() (*neoism.CypherQuery, *[]neoReadStruct) {
+	var results []neoReadStruct
+	return &neoism.CypherQuery{Statement: "MATCH (n) RETURN n", Result: &results}, &results
+}
+
+func TestCypherDriverRetriesTransientErrors(t *testing.T) {
+	assert := assert.New(t)
+	conn := &unreliableNeoConnection{fakeNeoConnection: fakeNeoConnection{rows: bankOfTestRows}, errs: []error{errConnectionReset, errNeoTransient}}
+	var waits []time.Duration
+	driver := retryingDriver(conn, RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}, &waits)
+
+	query, results := testQuery()
+	assert.NoError(driver.runQuery(context.Background(), "test", query))
+	assert.Equal(3, conn.calls)
+	assert.Len(waits, 2)
+	assert.Equal(bankOfTestRows, *results, "rows from failed attempts are discarded")
+}
+
+func TestCypherDriverGivesUpAfterMaxAttempts(t *testing.T) {
+	conn := &unreliableNeoConnection{errs: []error{errNeoTransient, errNeoTransient, errNeoTransient}}
+	var waits []time.Duration
+	driver := retryingDriver(conn, RetryPolicy{MaxAttempts: 2}, &waits)
+
+	query, _ := testQuery()
+	assert.Equal(t, errNeoTransient, driver.runQuery(context.Background(), "test", query))
+	assert.Equal(t, 2, conn.calls)
+}
+
+func TestCypherDriverDoesNotRetryPermanentErrors(t *testing.T) {
+	conn := &unreliableNeoConnection{errs: []error{errNeoSyntax}}
+	var waits []time.Duration
+	driver := retryingDriver(conn, RetryPolicy{MaxAttempts: 3}, &waits)
+
+	query, _ := testQuery()
+	assert.Equal(t, errNeoSyntax, driver.runQuery(context.Background(), "test", query))
+	assert.Equal(t, 1, conn.calls)
+}
+
+func TestCypherDriverDoesNotRetryWithoutAPolicy(t *testing.T) {
+	conn := &unreliableNeoConnection{errs: []error{errConnectionReset}}
+	driver := NewCypherDriver(conn, "prod")
+
+	query, _ := testQuery()
+	assert.Equal(t, errConnectionReset, driver.runQuery(context.Background(), "test", query))
+	assert.Equal(t, 1, conn.calls)
+}
+
+func TestCypherDriverQueriesOncePerRead(t *testing.T) {
+	conn := &unreliableNeoConnection{fakeNeoConnection: fakeNeoConnection{rows: bankOfTestRows}}
+	driver := NewCypherDriver(conn, "prod")
+
+	_, found, err := driver.ReadByConceptID(context.Background(), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	_, found, err = driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 2, conn.calls)
+}
+
+func TestCypherDriverRetriesRespectTheRequestDeadline(t *testing.T) {
+	assert := assert.New(t)
+	conn := &unreliableNeoConnection{errs: []error{errConnectionReset, errConnectionReset}}
+	var waits []time.Duration
+	driver := retryingDriver(conn, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, &waits)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	query, _ := testQuery()
+	assert.Equal(errConnectionReset, driver.runQuery(ctx, "test", query))
+	assert.Equal(1, conn.calls, "there's no time to wait for a retry before the deadline")
+	assert.Empty(waits)
+
+	conn = &unreliableNeoConnection{errs: []error{errConnectionReset, errConnectionReset}}
+	driver = retryingDriver(conn, RetryPolicy{MaxAttempts: 3}, &waits)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(errConnectionReset, driver.runQuery(ctx, "test", query))
+	assert.Equal(1, conn.calls, "cancelled requests aren't retried")
+}
+
+func TestRetryPolicyBackoffGrowsExponentiallyUpToTheMaximum(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 35 * time.Millisecond}
+	for retry, limit := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 35 * time.Millisecond, 10: 35 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			wait := policy.backoff(retry)
+			assert.True(t, wait >= 0 && wait < limit, "retry %d waited %v", retry, wait)
+		}
+	}
+	assert.Zero(t, RetryPolicy{}.backoff(1))
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		transient bool
+	}{
+		{errConnectionReset, true},
+		{fmt.Errorf("batch failed: %w", syscall.ECONNREFUSED), true},
+		{errNeoTransient, true},
+		{&errNeoTransient, true},
+		{neoism.TxQueryError{Errors: []neoism.TxError{{Code: "Neo.TransientError.General.DatabaseUnavailable"}}}, true},
+		{errors.New("unexpected response: 503 Service Unavailable"), true},
+		{errNeoSyntax, false},
+		{neoism.TxQueryError{Errors: []neoism.TxError{{Code: "Neo.ClientError.Statement.SyntaxError"}}}, false},
+		{ErrOverloaded, false},
+		{context.DeadlineExceeded, false},
+		{&url.Error{Op: "Post", URL: "http://localhost:7474/db/data/batch", Err: timeoutError{}}, false},
+	} {
+		assert.Equal(t, test.transient, isTransient(test.err), "%v", test.err)
+	}
+}
@@ -0,0 +1,333 @@
+package concordances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/rcrowley/go-metrics"
+)
+
+// RoutingStrategy chooses which of several Neo4j endpoints a read query is sent to
+type RoutingStrategy string
+
+const (
+	// RoundRobin takes turns between the healthy endpoints
+	RoundRobin RoutingStrategy = "round-robin"
+	// LeastLatency prefers the healthy endpoint which has been answering fastest recently
+	LeastLatency RoutingStrategy = "least-latency"
+)
+
+// ParseRoutingStrategy validates a routing strategy, defaulting to RoundRobin when it is empty
+func ParseRoutingStrategy(s string) (RoutingStrategy, bool) {
+	switch RoutingStrategy(s) {
+	case "":
+		return RoundRobin, true
+	case RoundRobin, LeastLatency:
+		return RoutingStrategy(s), true
+	}
+	return "", false
+}
+
+// latencyWeight is the weight of each new query in an endpoint's moving average latency
+const latencyWeight = 0.2
+
+// EndpointChecker is implemented by drivers which spread queries across several endpoints, so that the
+// healthcheck can report each of them
+type EndpointChecker interface {
+	Endpoints() []string
+	// CheckEndpoint returns the reason the named endpoint has been ejected, or nil if it is in use
+	CheckEndpoint(name string) error
+}
+
+// Endpoint is one of the stores a RoutingDriver spreads queries across
+type Endpoint struct {
+	// Name identifies the endpoint in logs, metrics and the healthcheck, e.g. its host and port
+	Name   string
+	Driver Driver
+}
+
+// EndpointName is the host and port of a Neo4j URL, which identifies it without exposing credentials
+func EndpointName(neoURL string) string {
+	u, err := url.Parse(neoURL)
+	if err != nil || u.Host == "" {
+		return neoURL
+	}
+	return u.Host
+}
+
+// RoutingOption configures a RoutingDriver
+type RoutingOption func(*RoutingDriver)
+
+// WithRoutingStrategy chooses how queries are spread across endpoints. The default is RoundRobin.
+func WithRoutingStrategy(strategy RoutingStrategy) RoutingOption {
+	return func(d *RoutingDriver) {
+		d.strategy = strategy
+	}
+}
+
+// WithEjection stops sending queries to an endpoint for ejectFor after it fails `after` queries in a row, or its
+// connectivity check fails. The defaults are 3 and 30s.
+func WithEjection(after int, ejectFor time.Duration) RoutingOption {
+	return func(d *RoutingDriver) {
+		d.ejectAfter = after
+		d.ejectFor = ejectFor
+	}
+}
+
+// WithRoutingClock replaces time.Now, which is used to time queries and ejections
+func WithRoutingClock(now func() time.Time) RoutingOption {
+	return func(d *RoutingDriver) {
+		d.now = now
+	}
+}
+
+// RoutingDriver spreads read queries across several endpoints, such as the members of a Neo4j cluster. A query which
+// fails with a transient error on one endpoint is retried on the next, and endpoints which keep failing are ejected
+// for a while. If every
+// endpoint has been ejected, queries are tried on all of them rather than failing outright.
+type RoutingDriver struct {
+	endpoints  []*routedEndpoint
+	strategy   RoutingStrategy
+	ejectAfter int
+	ejectFor   time.Duration
+	now        func() time.Time
+	next       uint64
+}
+
+type routedEndpoint struct {
+	name    string
+	driver  Driver
+	queries metrics.Timer
+	errors  metrics.Counter
+	ejected metrics.Gauge
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+	lastErr      error
+	latency      float64
+}
+
+// NewRoutingDriver spreads queries across endpoints, which must have unique names, as they key the endpoints'
+// metrics and healthchecks
+func NewRoutingDriver(endpoints []Endpoint, opts ...RoutingOption) (*RoutingDriver, error) {
+	d := &RoutingDriver{strategy: RoundRobin, ejectAfter: 3, ejectFor: 30 * time.Second, now: time.Now}
+	for _, opt := range opts {
+		opt(d)
+	}
+	names := map[string]bool{}
+	for _, e := range endpoints {
+		if names[e.Name] {
+			return nil, fmt.Errorf("Neo4j endpoint %s is given more than once", e.Name)
+		}
+		names[e.Name] = true
+		prefix := "concordances.neo4j.endpoint." + metricName(e.Name)
+		d.endpoints = append(d.endpoints, &routedEndpoint{
+			name:    e.Name,
+			driver:  e.Driver,
+			queries: metrics.GetOrRegisterTimer(prefix+".queries", metrics.DefaultRegistry),
+			errors:  metrics.GetOrRegisterCounter(prefix+".errors", metrics.DefaultRegistry),
+			ejected: metrics.GetOrRegisterGauge(prefix+".ejected", metrics.DefaultRegistry),
+		})
+	}
+	return d, nil
+}
+
+// metricName stops the separators in host names and ports from splitting metric paths
+func metricName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "/", "_").Replace(name)
+}
+
+// ReadByConceptID runs the query on the first endpoint to answer it
+func (d *RoutingDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	return d.read(ctx, func(driver Driver) (Concordances, bool, error) {
+		return driver.ReadByConceptID(ctx, ids)
+	})
+}
+
+// ReadByAuthority runs the query on the first endpoint to answer it
+func (d *RoutingDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	return d.read(ctx, func(driver Driver) (Concordances, bool, error) {
+		return driver.ReadByAuthority(ctx, authority, ids)
+	})
+}
+
+func (d *RoutingDriver) read(ctx context.Context, query func(Driver) (Concordances, bool, error)) (Concordances, bool, error) {
+	var err error
+	for _, e := range d.candidates() {
+		start := d.now()
+		var c Concordances
+		var found bool
+		c, found, err = query(e.driver)
+		elapsed := d.now().Sub(start)
+		if err == nil {
+			e.succeeded(elapsed)
+			return c, found, nil
+		}
+		// Only transient errors say anything about the endpoint. Errors in the query or its input would fail the same way
+		// on every endpoint, and for cancelled queries there's no one left waiting for an answer.
+		if ctx.Err() != nil || !isTransient(err) {
+			return c, found, err
+		}
+		e.failed(err, d.ejectAfter, d.now(), d.ejectFor)
+	}
+	return Concordances{}, false, err
+}
+
+// candidates orders the endpoints a query should be tried on
+func (d *RoutingDriver) candidates() []*routedEndpoint {
+	now := d.now()
+	available := make([]*routedEndpoint, 0, len(d.endpoints))
+	for _, e := range d.endpoints {
+		if e.available(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		available = append(available, d.endpoints...)
+	}
+
+	// Rotating the starting point takes turns in round robin, and between equally fast endpoints otherwise
+	start := int(atomic.AddUint64(&d.next, 1) % uint64(len(available)))
+	ordered := append(available[start:len(available):len(available)], available[:start]...)
+	if d.strategy == LeastLatency {
+		latencies := make(map[*routedEndpoint]float64, len(ordered))
+		for _, e := range ordered {
+			latencies[e] = e.averageLatency()
+		}
+		sort.SliceStable(ordered, func(i, j int) bool { return latencies[ordered[i]] < latencies[ordered[j]] })
+	}
+	return ordered
+}
+
+// CheckConnectivity checks every endpoint, ejecting those which fail and restoring those which have recovered.
+// It succeeds if any endpoint can be queried.
+func (d *RoutingDriver) CheckConnectivity() error {
+	var errs []string
+	for _, e := range d.endpoints {
+		err := e.driver.CheckConnectivity()
+		e.checked(err, d.now(), d.ejectFor)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.name, err))
+		}
+	}
+	if len(errs) == len(d.endpoints) {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// CheckIndexes checks the indexes of every endpoint which can check them
+func (d *RoutingDriver) CheckIndexes() error {
+	for _, e := range d.endpoints {
+		if checker, ok := e.driver.(IndexChecker); ok {
+			if err := checker.CheckIndexes(); err != nil {
+				return fmt.Errorf("%s: %v", e.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Endpoints names the endpoints queries are spread across
+func (d *RoutingDriver) Endpoints() []string {
+	names := make([]string, len(d.endpoints))
+	for i, e := range d.endpoints {
+		names[i] = e.name
+	}
+	return names
+}
+
+// CheckEndpoint returns the error the named endpoint was ejected for, if it is ejected
+func (d *RoutingDriver) CheckEndpoint(name string) error {
+	now := d.now()
+	for _, e := range d.endpoints {
+		if e.name == name {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if now.Before(e.ejectedUntil) {
+				return e.lastErr
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown endpoint %s", name)
+}
+
+func (e *routedEndpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+func (e *routedEndpoint) averageLatency() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latency
+}
+
+func (e *routedEndpoint) succeeded(elapsed time.Duration) {
+	e.queries.Update(elapsed)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	ms := elapsed.Seconds() * 1000
+	if e.latency == 0 {
+		e.latency = ms
+	} else {
+		e.latency = latencyWeight*ms + (1-latencyWeight)*e.latency
+	}
+	if !e.ejectedUntil.IsZero() {
+		e.readmit()
+	}
+}
+
+func (e *routedEndpoint) failed(err error, ejectAfter int, now time.Time, ejectFor time.Duration) {
+	e.errors.Inc(1)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	e.lastErr = err
+	if e.failures >= ejectAfter {
+		e.eject(err, now, ejectFor)
+	}
+}
+
+func (e *routedEndpoint) checked(err error, now time.Time, ejectFor time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.eject(err, now, ejectFor)
+		return
+	}
+	e.failures = 0
+	if !e.ejectedUntil.IsZero() {
+		e.readmit()
+	}
+}
+
+// eject must be called with e.mu held
+func (e *routedEndpoint) eject(err error, now time.Time, ejectFor time.Duration) {
+	if !now.Before(e.ejectedUntil) {
+		log.WithError(err).WithField("endpoint", e.name).Warn("Ejecting failing Neo4j endpoint")
+	}
+	e.ejectedUntil = now.Add(ejectFor)
+	e.lastErr = err
+	e.ejected.Update(1)
+}
+
+// readmit must be called with e.mu held
+func (e *routedEndpoint) readmit() {
+	log.WithField("endpoint", e.name).Info("Neo4j endpoint has recovered")
+	e.ejectedUntil = time.Time{}
+	e.lastErr = nil
+	e.ejected.Update(0)
+}
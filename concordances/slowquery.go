@@ -0,0 +1,161 @@
+package concordances
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/jmcvetta/neoism"
+	"github.com/rcrowley/go-metrics"
+)
+
+// maxConcurrentProfiles bounds the PROFILE queries in flight, so that a run of slow queries while Neo4j struggles
+// doesn't send it a burst of extra expensive ones. Slow queries arriving while the profiler is busy aren't profiled.
+const maxConcurrentProfiles = 1
+
+var skippedProfiles = metrics.GetOrRegisterCounter("concordances.slow_query.profiles_skipped", metrics.DefaultRegistry)
+
+// WithSlowQueryLog logs every Cypher execution that takes longer than threshold. A zero threshold disables it.
+func WithSlowQueryLog(threshold time.Duration) CypherDriverOption {
+	return func(d *CypherDriver) {
+		d.slowQueryThreshold = threshold
+	}
+}
+
+// WithQueryProfiling re-runs slow queries with PROFILE against the transactional endpoint under neoURL
+// and logs the resulting plan. It is meant for debugging index usage and only applies when the slow query log is on.
+func WithQueryProfiling(neoURL string, client *http.Client) CypherDriverOption {
+	return func(d *CypherDriver) {
+		d.profiler = &queryProfiler{
+			url:     strings.TrimSuffix(neoURL, "/") + "/transaction/commit",
+			client:  client,
+			running: make(chan struct{}, maxConcurrentProfiles),
+		}
+	}
+}
+
+func (pcw CypherDriver) logIfSlow(statementName string, query *neoism.CypherQuery, duration time.Duration) {
+	if pcw.slowQueryThreshold <= 0 || duration < pcw.slowQueryThreshold {
+		return
+	}
+
+	log.WithFields(map[string]interface{}{
+		"statement_name":  statementName,
+		"parameter_sizes": parameterSizes(query.Parameters),
+		"duration_ms":     duration.Seconds() * 1000,
+		"row_count":       resultCount(query.Result),
+	}).Warn("Slow Cypher query")
+
+	if pcw.profiler != nil {
+		pcw.profiler.start(statementName, query)
+	}
+}
+
+// parameterSizes reports the length of each list parameter, and 1 for scalars
+func parameterSizes(params map[string]interface{}) map[string]int {
+	sizes := make(map[string]int, len(params))
+	for name, value := range params {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			sizes[name] = v.Len()
+		} else {
+			sizes[name] = 1
+		}
+	}
+	return sizes
+}
+
+type queryProfiler struct {
+	url     string
+	client  *http.Client
+	running chan struct{}
+}
+
+type txStatement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+type txRequest struct {
+	Statements []txStatement `json:"statements"`
+}
+
+type txResponse struct {
+	Results []struct {
+		Plan json.RawMessage `json:"plan"`
+	} `json:"results"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// start profiles query in the background, unless maxConcurrentProfiles profiles are already running
+func (p *queryProfiler) start(statementName string, query *neoism.CypherQuery) bool {
+	select {
+	case p.running <- struct{}{}:
+	default:
+		skippedProfiles.Inc(1)
+		return false
+	}
+	go func() {
+		defer func() { <-p.running }()
+		p.profile(statementName, query)
+	}()
+	return true
+}
+
+func (p *queryProfiler) profile(statementName string, query *neoism.CypherQuery) {
+	plan, err := p.plan(query)
+	if err != nil {
+		log.WithError(err).WithField("statement_name", statementName).Warn("Failed to profile slow Cypher query")
+		return
+	}
+	log.WithFields(map[string]interface{}{
+		"statement_name": statementName,
+		"plan":           string(plan),
+	}).Info("Query plan for slow Cypher query")
+}
+
+func (p *queryProfiler) plan(query *neoism.CypherQuery) (json.RawMessage, error) {
+	body, err := json.Marshal(txRequest{Statements: []txStatement{{
+		Statement:  "PROFILE " + query.Statement,
+		Parameters: query.Parameters,
+	}}})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json; charset=UTF-8")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, p.url)
+	}
+
+	var result txResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("%s: %s", result.Errors[0].Code, result.Errors[0].Message)
+	}
+	if len(result.Results) == 0 || len(result.Results[0].Plan) == 0 {
+		return nil, fmt.Errorf("no plan returned for profiled query")
+	}
+	return result.Results[0].Plan, nil
+}
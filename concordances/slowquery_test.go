@@ -0,0 +1,134 @@
+package concordances
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/jmcvetta/neoism"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNeoConnection answers every query with rows, after an optional delay
+type fakeNeoConnection struct {
+	delay          time.Duration
+	rows           []neoReadStruct
+	indexes        []indexRow
+	err            error
+	ensuredIndexes []map[string]string
+}
+
+func (f *fakeNeoConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return f.err
+	}
+	for _, q := range queries {
+		switch results := q.Result.(type) {
+		case *[]neoReadStruct:
+			*results = append([]neoReadStruct{}, f.rows...)
+		case *[]indexRow:
+			*results = append([]indexRow{}, f.indexes...)
+		}
+	}
+	return nil
+}
+
+func (f *fakeNeoConnection) EnsureConstraints(constraints map[string]string) error {
+	return nil
+}
+
+func (f *fakeNeoConnection) EnsureIndexes(indexes map[string]string) error {
+	f.ensuredIndexes = append(f.ensuredIndexes, indexes)
+	return nil
+}
+
+var bankOfTestRows = []neoReadStruct{
+	{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "FACTSET", AuthorityValue: "7IV872-E"},
+	{CanonicalUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Types: []string{"Thing", "Concept", "Organisation"}, Authority: "UPP", AuthorityValue: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+}
+
+func TestSlowQueryIsLoggedWithStatementNameAndParameterSizes(t *testing.T) {
+	assert := assert.New(t)
+	hook := test.NewLocal(logger.Logger())
+	defer hook.Reset()
+
+	conn := &fakeNeoConnection{delay: 5 * time.Millisecond, rows: bankOfTestRows}
+	driver := NewCypherDriver(conn, "prod", WithSlowQueryLog(time.Millisecond))
+	_, found, err := driver.ReadByConceptID(context.Background(), []string{"a", "b", "c"})
+	assert.NoError(err)
+	assert.True(found)
+
+	var slow []map[string]interface{}
+	for _, e := range hook.AllEntries() {
+		if e.Message == "Slow Cypher query" {
+			slow = append(slow, e.Data)
+		}
+	}
+	if assert.NotEmpty(slow) {
+		assert.Equal("concordancesByConceptID", slow[0]["statement_name"])
+		assert.Equal(map[string]int{"identifiers": 3}, slow[0]["parameter_sizes"])
+		assert.Equal(2, slow[0]["row_count"])
+	}
+}
+
+func TestFastQueryIsNotLogged(t *testing.T) {
+	assert := assert.New(t)
+	hook := test.NewLocal(logger.Logger())
+	defer hook.Reset()
+
+	driver := NewCypherDriver(&fakeNeoConnection{rows: bankOfTestRows}, "prod", WithSlowQueryLog(time.Minute))
+	_, _, err := driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{"7IV872-E"})
+	assert.NoError(err)
+
+	for _, e := range hook.AllEntries() {
+		assert.NotEqual("Slow Cypher query", e.Message)
+	}
+}
+
+func TestQueryProfilerSendsProfiledStatementAndReturnsPlan(t *testing.T) {
+	assert := assert.New(t)
+	var received txRequest
+	neo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/db/data/transaction/commit", r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.Write([]byte(`{"results":[{"columns":[],"data":[],"plan":{"root":{"operatorType":"NodeIndexSeek"}}}],"errors":[]}`))
+	}))
+	defer neo.Close()
+
+	profiler := &queryProfiler{url: neo.URL + "/db/data/transaction/commit", client: neo.Client()}
+	plan, err := profiler.plan(&neoism.CypherQuery{
+		Statement:  "MATCH (p:Thing) WHERE p.uuid IN {identifiers} RETURN p",
+		Parameters: neoism.Props{"identifiers": []string{"a"}},
+	})
+	assert.NoError(err)
+	assert.JSONEq(`{"root":{"operatorType":"NodeIndexSeek"}}`, string(plan))
+	if assert.Len(received.Statements, 1) {
+		assert.Equal("PROFILE MATCH (p:Thing) WHERE p.uuid IN {identifiers} RETURN p", received.Statements[0].Statement)
+	}
+}
+
+func TestQueryProfilerSkipsSlowQueriesWhileBusy(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	neo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"results":[{"plan":{}}],"errors":[]}`))
+	}))
+	defer neo.Close()
+
+	profiler := &queryProfiler{url: neo.URL, client: neo.Client(), running: make(chan struct{}, maxConcurrentProfiles)}
+	query := &neoism.CypherQuery{Statement: "MATCH (p:Thing) RETURN p"}
+	assert.True(profiler.start("slow", query))
+	assert.False(profiler.start("slow", query), "a profile is already running")
+
+	close(release)
+	assert.Eventually(func() bool { return profiler.start("slow", query) }, time.Second, 10*time.Millisecond)
+}
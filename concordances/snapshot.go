@@ -0,0 +1,600 @@
+package concordances
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/rcrowley/go-metrics"
+)
+
+const snapshotVersion = 2
+
+var (
+	snapshotReloadFailures = metrics.GetOrRegisterCounter("concordances.snapshot.reload_failures", metrics.DefaultRegistry)
+	snapshotConcepts       = metrics.GetOrRegisterGauge("concordances.snapshot.concepts", metrics.DefaultRegistry)
+	snapshotCreated        = metrics.GetOrRegisterGauge("concordances.snapshot.created", metrics.DefaultRegistry)
+)
+
+// SnapshotConcept is a canonical concept and the source concepts concorded to it, as stored in a snapshot
+type SnapshotConcept struct {
+	PrefUUID string
+	Types    []string
+	LEICode  string
+	ISO31661 string
+	// Sources are the concepts EQUIVALENT_TO the canonical one
+	Sources []SnapshotSource
+}
+
+// SnapshotSource is a source concept, identified by its UPP UUID and its ID in the authority it came from
+type SnapshotSource struct {
+	UUID           string
+	Authority      string
+	AuthorityValue string
+}
+
+// A snapshot file starts with snapshotMagic, followed by two tables: the concepts, keyed by prefUUID, and the
+// lookups, mapping each UUID and identifier to the prefUUIDs of the concepts it belongs to. Then come the tables'
+// block indexes, the manifest as JSON, and a footer locating the indexes and manifest. Lookups only read the blocks
+// they need from the file, so serving a snapshot takes little memory however large it is.
+const (
+	snapshotMagic = "CONCSNAP"
+	// snapshotFooterSize is eight uint64s, then snapshotMagic again
+	snapshotFooterSize = 8*8 + 8
+)
+
+// Lookup keys start with the kind of ID, so that the same value in different kinds can't be confused
+const (
+	uuidLookup       = "uuid\x00"
+	identifierLookup = "identifier\x00"
+	leiLookup        = "lei\x00"
+	iso31661Lookup   = "iso31661\x00"
+)
+
+func identifierLookupKey(authority string, value string) string {
+	return identifierLookup + authority + "\x00" + value
+}
+
+// SnapshotManifest summarises a snapshot, so that it can be checked before it is served
+type SnapshotManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Concepts  int       `json:"concepts"`
+	// Authorities counts the identifiers in each authority, named as in Neo4j
+	Authorities map[string]int `json:"authorities"`
+	// SHA256 is the checksum of the file up to the manifest, covering the concepts and the lookups
+	SHA256 string `json:"sha256"`
+}
+
+// SnapshotWriter writes the concepts of a snapshot. Concepts are written to the file as they are added, but the
+// lookups are held in memory until the snapshot is closed, as they have to be sorted.
+type SnapshotWriter struct {
+	buf      *bufio.Writer
+	sum      hash.Hash
+	out      *countingWriter
+	concepts tableWriter
+	lookups  map[string][]string
+	manifest SnapshotManifest
+}
+
+// NewSnapshotWriter starts a snapshot taken at createdAt. The snapshot is only complete once it is closed.
+func NewSnapshotWriter(w io.Writer, createdAt time.Time) (*SnapshotWriter, error) {
+	sw := &SnapshotWriter{buf: bufio.NewWriter(w), sum: sha256.New(), lookups: map[string][]string{}, manifest: SnapshotManifest{
+		Version:     snapshotVersion,
+		CreatedAt:   createdAt.UTC(),
+		Authorities: map[string]int{},
+	}}
+	sw.out = &countingWriter{w: io.MultiWriter(sw.buf, sw.sum)}
+	sw.concepts.out = sw.out
+	if _, err := sw.out.Write([]byte(snapshotMagic)); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write adds a concept to the snapshot. Concepts must be written in prefUUID order.
+func (w *SnapshotWriter) Write(c SnapshotConcept) error {
+	if err := w.concepts.add(c.PrefUUID, c.encode()); err != nil {
+		return err
+	}
+	w.manifest.Concepts++
+	c.countIdentifiers(w.manifest.Authorities)
+	for _, key := range c.lookupKeys() {
+		w.lookups[key] = appendUnique(w.lookups[key], c.PrefUUID)
+	}
+	return nil
+}
+
+// Close completes the snapshot by writing its lookups and manifest. It doesn't close the underlying writer.
+func (w *SnapshotWriter) Close() error {
+	if err := w.concepts.flush(); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(w.lookups))
+	for key := range w.lookups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lookups := tableWriter{out: w.out}
+	for _, key := range keys {
+		if err := lookups.add(key, appendStrings(nil, w.lookups[key])); err != nil {
+			return err
+		}
+	}
+	if err := lookups.flush(); err != nil {
+		return err
+	}
+
+	conceptsIndex := w.out.n
+	if _, err := w.out.Write(w.concepts.index); err != nil {
+		return err
+	}
+	lookupsIndex := w.out.n
+	if _, err := w.out.Write(lookups.index); err != nil {
+		return err
+	}
+
+	// The manifest and footer aren't part of the checksum, which is in the manifest
+	w.manifest.SHA256 = hex.EncodeToString(w.sum.Sum(nil))
+	manifest, err := json.Marshal(w.manifest)
+	if err != nil {
+		return err
+	}
+	footer := make([]byte, 0, snapshotFooterSize)
+	for _, v := range []int64{
+		conceptsIndex, lookupsIndex - conceptsIndex,
+		lookupsIndex, w.out.n - lookupsIndex,
+		w.out.n, int64(len(manifest)),
+		snapshotVersion, 0,
+	} {
+		footer = binary.BigEndian.AppendUint64(footer, uint64(v))
+	}
+	footer = append(footer, snapshotMagic...)
+	if _, err := w.buf.Write(manifest); err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(footer); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+// Manifest summarises the snapshot once it is closed
+func (w *SnapshotWriter) Manifest() SnapshotManifest {
+	return w.manifest
+}
+
+// WriteSnapshotFile writes a snapshot with write, replacing the file at path only once it is complete, so that
+// drivers reloading the file never see part of one
+func WriteSnapshotFile(path string, createdAt time.Time, write func(*SnapshotWriter) error) (SnapshotManifest, error) {
+	var w *SnapshotWriter
+	err := replaceFile(path, func(f *os.File) error {
+		var err error
+		if w, err = NewSnapshotWriter(f, createdAt); err != nil {
+			return err
+		}
+		if err = write(w); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return SnapshotManifest{}, err
+	}
+	return w.Manifest(), nil
+}
+
+// WriteSnapshotManifestFile writes manifest as indented JSON, replacing the file at path only once it is complete
+func WriteSnapshotManifestFile(path string, manifest SnapshotManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, func(f *os.File) error {
+		if err := f.Chmod(0644); err != nil {
+			return err
+		}
+		_, err := f.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// replaceFile writes a temporary file alongside path, then renames it over path, so that readers see either the old
+// file or the whole of the new one
+func replaceFile(path string, write func(*os.File) error) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// snapshot is an open snapshot file
+type snapshot struct {
+	file     *os.File
+	manifest SnapshotManifest
+	concepts *table
+	lookups  *table
+}
+
+// openSnapshot checks the snapshot file at path against its manifest and reads its block indexes
+func openSnapshot(path string) (*snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := readSnapshot(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading snapshot %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func readSnapshot(f *os.File) (*snapshot, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	magic := make([]byte, len(snapshotMagic))
+	if size < int64(len(snapshotMagic)) {
+		return nil, errors.New("not a concordances snapshot")
+	}
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+	if string(magic) != snapshotMagic {
+		return nil, errors.New("not a concordances snapshot")
+	}
+	footer := make([]byte, snapshotFooterSize)
+	if size < int64(len(snapshotMagic)+snapshotFooterSize) {
+		return nil, errors.New("snapshot is incomplete")
+	}
+	if _, err := f.ReadAt(footer, size-snapshotFooterSize); err != nil {
+		return nil, err
+	}
+	if string(footer[snapshotFooterSize-len(snapshotMagic):]) != snapshotMagic {
+		return nil, errors.New("snapshot is incomplete")
+	}
+	var sections [8]int64
+	for i := range sections {
+		sections[i] = int64(binary.BigEndian.Uint64(footer[i*8:]))
+	}
+	if sections[6] != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", sections[6])
+	}
+	// The indexes and manifest follow each other, and the footer follows the manifest
+	conceptsIndex, lookupsIndex, manifest := sections[0:2], sections[2:4], sections[4:6]
+	if conceptsIndex[0] < int64(len(snapshotMagic)) || conceptsIndex[1] < 0 || lookupsIndex[1] < 0 || manifest[1] < 0 ||
+		lookupsIndex[0] != conceptsIndex[0]+conceptsIndex[1] || manifest[0] != lookupsIndex[0]+lookupsIndex[1] ||
+		manifest[0]+manifest[1] != size-snapshotFooterSize {
+		return nil, errDamagedSnapshot
+	}
+
+	s := &snapshot{file: f}
+	data := make([]byte, manifest[1])
+	if _, err := f.ReadAt(data, manifest[0]); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.manifest); err != nil {
+		return nil, fmt.Errorf("manifest: %v", err)
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, io.NewSectionReader(f, 0, manifest[0])); err != nil {
+		return nil, err
+	}
+	if s.manifest.SHA256 != hex.EncodeToString(sum.Sum(nil)) {
+		return nil, errors.New("snapshot checksum doesn't match its contents")
+	}
+
+	if s.concepts, err = readSnapshotTable(f, conceptsIndex); err != nil {
+		return nil, err
+	}
+	if s.lookups, err = readSnapshotTable(f, lookupsIndex); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readSnapshotTable reads the index at section, an offset and length, of a table whose blocks come before it
+func readSnapshotTable(f *os.File, section []int64) (*table, error) {
+	index := make([]byte, section[1])
+	if _, err := f.ReadAt(index, section[0]); err != nil {
+		return nil, err
+	}
+	return readTable(f, index, section[0])
+}
+
+func (s *snapshot) close() error {
+	return s.file.Close()
+}
+
+// concept returns the concept with the prefUUID
+func (s *snapshot) concept(prefUUID string) (SnapshotConcept, bool, error) {
+	value, found, err := s.concepts.get(prefUUID)
+	if err != nil || !found {
+		return SnapshotConcept{}, false, err
+	}
+	c, err := decodeSnapshotConcept(prefUUID, value)
+	return c, err == nil, err
+}
+
+// lookup returns the concepts the lookup key belongs to
+func (s *snapshot) lookup(key string) ([]SnapshotConcept, error) {
+	value, found, err := s.lookups.get(key)
+	if err != nil || !found {
+		return nil, err
+	}
+	d := snapshotDecoder{buf: value}
+	prefUUIDs := d.strings()
+	if d.err != nil {
+		return nil, d.err
+	}
+	var concepts []SnapshotConcept
+	for _, prefUUID := range prefUUIDs {
+		c, found, err := s.concept(prefUUID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errDamagedSnapshot
+		}
+		concepts = append(concepts, c)
+	}
+	return concepts, nil
+}
+
+func (c *SnapshotConcept) encode() []byte {
+	b := appendStrings(nil, c.Types)
+	b = appendString(b, c.LEICode)
+	b = appendString(b, c.ISO31661)
+	b = appendUvarint(b, uint64(len(c.Sources)))
+	for _, source := range c.Sources {
+		b = appendString(b, source.UUID)
+		b = appendString(b, source.Authority)
+		b = appendString(b, source.AuthorityValue)
+	}
+	return b
+}
+
+func decodeSnapshotConcept(prefUUID string, value []byte) (SnapshotConcept, error) {
+	d := snapshotDecoder{buf: value}
+	c := SnapshotConcept{PrefUUID: prefUUID, Types: d.strings(), LEICode: d.string(), ISO31661: d.string()}
+	n := d.uvarint()
+	// Each source takes at least three bytes
+	if d.err == nil && n > uint64(len(d.buf)) {
+		d.err = errDamagedSnapshot
+	}
+	for i := uint64(0); d.err == nil && i < n; i++ {
+		c.Sources = append(c.Sources, SnapshotSource{UUID: d.string(), Authority: d.string(), AuthorityValue: d.string()})
+	}
+	if d.err != nil {
+		return SnapshotConcept{}, d.err
+	}
+	return c, nil
+}
+
+// lookupKeys are the keys the concept can be looked up by
+func (c *SnapshotConcept) lookupKeys() []string {
+	var keys []string
+	for _, source := range c.Sources {
+		keys = append(keys, uuidLookup+source.UUID)
+		if source.Authority != "" {
+			keys = append(keys, identifierLookupKey(source.Authority, source.AuthorityValue))
+		}
+	}
+	if c.LEICode != "" {
+		keys = append(keys, leiLookup+c.LEICode)
+	}
+	if c.ISO31661 != "" && c.isLocation() {
+		keys = append(keys, iso31661Lookup+c.ISO31661)
+	}
+	return keys
+}
+
+// countIdentifiers adds the identifiers the concept can be looked up by to counts
+func (c *SnapshotConcept) countIdentifiers(counts map[string]int) {
+	for _, source := range c.Sources {
+		if source.Authority != "" {
+			counts[source.Authority]++
+		}
+		counts["UPP"]++
+	}
+	if c.LEICode != "" {
+		counts["LEI"]++
+	}
+	if c.ISO31661 != "" && c.isLocation() {
+		counts["ISO-3166-1"]++
+	}
+}
+
+func (c *SnapshotConcept) isLocation() bool {
+	for _, t := range c.Types {
+		if t == "Location" {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the source concept with the UUID
+func (c *SnapshotConcept) source(uuid string) *SnapshotSource {
+	for i := range c.Sources {
+		if c.Sources[i].UUID == uuid {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// row builds the Cypher result row the same lookup would have returned from Neo4j
+func (c *SnapshotConcept) row(authority string, value string, branch string, requested *SnapshotSource) neoReadStruct {
+	row := neoReadStruct{CanonicalUUID: c.PrefUUID, Types: c.Types, Authority: authority, AuthorityValue: value, Branch: branch}
+	if requested != nil {
+		row.RequestedUUID = requested.UUID
+		row.RequestedAuthority = requested.Authority
+	}
+	return row
+}
+
+// SnapshotDriver answers from a snapshot file rather than Neo4j, for read-only replicas which can't reach it.
+// Lookups read what they need from the file, so only the snapshot's block indexes, about a key for every 4KB of
+// the file, are held in memory.
+type SnapshotDriver struct {
+	path     string
+	env      string
+	mu       sync.RWMutex
+	snapshot *snapshot
+}
+
+// NewSnapshotDriver opens the snapshot file at path
+func NewSnapshotDriver(path string, env string) (*SnapshotDriver, error) {
+	d := &SnapshotDriver{path: path, env: env}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CreatedAt is when the snapshot being served was taken
+func (d *SnapshotDriver) CreatedAt() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.snapshot.manifest.CreatedAt
+}
+
+// Reload replaces the snapshot being served with the contents of the file. If the file is invalid, the current
+// snapshot is kept. Lookups in progress finish with the old snapshot before its file is closed.
+func (d *SnapshotDriver) Reload() error {
+	s, err := openSnapshot(d.path)
+	if err != nil {
+		snapshotReloadFailures.Inc(1)
+		return err
+	}
+	d.mu.Lock()
+	old := d.snapshot
+	d.snapshot = s
+	d.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	snapshotConcepts.Update(int64(s.manifest.Concepts))
+	snapshotCreated.Update(s.manifest.CreatedAt.Unix())
+	return nil
+}
+
+// Watch reloads the snapshot whenever the file changes or the process receives a SIGHUP, checking every interval
+// until stopped
+func (d *SnapshotDriver) Watch(interval time.Duration) (stop func()) {
+	return watchFile(d.path, interval, func() {
+		if err := d.Reload(); err != nil {
+			log.WithError(err).Error("Failed to reload concordances snapshot, keeping the previous one")
+			return
+		}
+		log.WithField("path", d.path).WithField("created_at", d.CreatedAt()).Info("Reloaded concordances snapshot")
+	})
+}
+
+// CheckConnectivity always succeeds, as a snapshot is opened before the driver is created
+func (d *SnapshotDriver) CheckConnectivity() error {
+	return nil
+}
+
+// ReadByConceptID returns every identifier of the concepts the UUIDs are concorded to
+func (d *SnapshotDriver) ReadByConceptID(ctx context.Context, ids []string) (Concordances, bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var rows []neoReadStruct
+	for _, id := range ids {
+		concepts, err := d.snapshot.lookup(uuidLookup + id)
+		if err != nil {
+			return Concordances{}, false, err
+		}
+		for _, c := range concepts {
+			requested := c.source(id)
+			for _, source := range c.Sources {
+				rows = append(rows,
+					c.row(source.Authority, source.AuthorityValue, "leafNode", requested),
+					c.row("UPP", source.UUID, "leafNodeUPP", requested))
+			}
+			if c.LEICode != "" {
+				rows = append(rows, c.row("LEI", c.LEICode, "canonicalLEI", requested))
+			}
+			if c.ISO31661 != "" && c.isLocation() {
+				rows = append(rows, c.row("ISO-3166-1", c.ISO31661, "canonicalISO31661", requested))
+			}
+		}
+	}
+	return d.concordances(ctx, rows)
+}
+
+// ReadByAuthority returns the concepts the identifiers in the authority are concorded to
+func (d *SnapshotDriver) ReadByAuthority(ctx context.Context, authority string, ids []string) (Concordances, bool, error) {
+	authorityProperty, found := authoritiesFromContext(ctx).fromURI(authority)
+	if !found {
+		return Concordances{}, false, nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var rows []neoReadStruct
+	for _, id := range ids {
+		key, branch := identifierLookupKey(authorityProperty, id), "leafNode"
+		switch authorityProperty {
+		case "UPP":
+			key, branch = uuidLookup+id, "leafNodeUPP"
+		case "LEI":
+			key, branch = leiLookup+id, "canonicalLEI"
+		case "ISO-3166-1":
+			key, branch = iso31661Lookup+id, "canonicalISO31661"
+		}
+		concepts, err := d.snapshot.lookup(key)
+		if err != nil {
+			return Concordances{}, false, err
+		}
+		for _, c := range concepts {
+			rows = append(rows, c.row(authorityProperty, id, branch, nil))
+		}
+	}
+	return d.concordances(ctx, rows)
+}
+
+// concordances shapes rows the way CypherDriver does, so both drivers answer alike
+func (d *SnapshotDriver) concordances(ctx context.Context, rows []neoReadStruct) (Concordances, bool, error) {
+	if len(rows) == 0 {
+		return Concordances{}, false, nil
+	}
+	concordances := neoReadStructToConcordances(rows, d.env, authoritiesFromContext(ctx), debugFromContext(ctx))
+	if len(concordances.Concordance) == 0 {
+		return Concordances{}, false, nil
+	}
+	return concordances, true, nil
+}
@@ -0,0 +1,76 @@
+package concordances
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmcvetta/neoism"
+)
+
+// snapshotRow is a source concept of a canonical concept, or just the canonical concept if it has no sources
+type snapshotRow struct {
+	CanonicalUUID  string   `json:"canonicalUUID"`
+	Types          []string `json:"types"`
+	LEICode        string   `json:"leiCode"`
+	ISO31661       string   `json:"iso31661"`
+	UUID           string   `json:"UUID"`
+	Authority      string   `json:"authority"`
+	AuthorityValue string   `json:"authorityValue"`
+}
+
+// BuildSnapshot writes every canonical concept in Neo4j, with its LEI code or ISO 3166-1 code if it has one and the
+// source concepts concorded to it. Concepts are read pageSize at a time, in prefUUID order, so each query stays small
+// however many concepts there are.
+func (pcw CypherDriver) BuildSnapshot(ctx context.Context, w *SnapshotWriter, pageSize int) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("snapshot page size must be positive, not %d", pageSize)
+	}
+	after := ""
+	for {
+		var rows []snapshotRow
+		query := &neoism.CypherQuery{
+			// The OPTIONAL MATCH keeps canonical concepts without sources, so that every page ends with its last concept
+			Statement: `
+		MATCH (canonical:Concept)
+		WHERE exists(canonical.prefUUID) AND canonical.prefUUID > {after}
+		WITH DISTINCT canonical
+		ORDER BY canonical.prefUUID
+		LIMIT {limit}
+		OPTIONAL MATCH (canonical)<-[:EQUIVALENT_TO]-(leafNode:Thing)
+		RETURN canonical.prefUUID AS canonicalUUID, labels(canonical) AS types, canonical.leiCode AS leiCode, canonical.iso31661 AS iso31661, leafNode.uuid AS UUID, leafNode.authority AS authority, leafNode.authorityValue AS authorityValue
+		ORDER BY canonicalUUID, UUID`,
+			Parameters: neoism.Props{"after": after, "limit": pageSize},
+			Result:     &rows,
+		}
+		if err := pcw.runQuery(ctx, "snapshotPage", query); err != nil {
+			return fmt.Errorf("reading concepts after %q from Neo4j: %v", after, err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := writeSnapshotRows(w, rows); err != nil {
+			return err
+		}
+		after = rows[len(rows)-1].CanonicalUUID
+	}
+}
+
+// writeSnapshotRows groups a page of rows, which are sorted by concept, into concepts
+func writeSnapshotRows(w *SnapshotWriter, rows []snapshotRow) error {
+	var concept *SnapshotConcept
+	for _, row := range rows {
+		if concept != nil && concept.PrefUUID != row.CanonicalUUID {
+			if err := w.Write(*concept); err != nil {
+				return err
+			}
+			concept = nil
+		}
+		if concept == nil {
+			concept = &SnapshotConcept{PrefUUID: row.CanonicalUUID, Types: row.Types, LEICode: row.LEICode, ISO31661: row.ISO31661}
+		}
+		if row.UUID != "" {
+			concept.Sources = append(concept.Sources, SnapshotSource{UUID: row.UUID, Authority: row.Authority, AuthorityValue: row.AuthorityValue})
+		}
+	}
+	return w.Write(*concept)
+}
@@ -0,0 +1,201 @@
+package concordances
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// snapshotBlockSize is roughly how many bytes of a table are read from disk for each lookup. Only the first key of
+// each block is held in memory.
+const snapshotBlockSize = 4096
+
+var errDamagedSnapshot = errors.New("snapshot is damaged")
+
+// A table is a sorted list of keys and values, stored in a snapshot as blocks of entries followed by an index of the
+// blocks. Each entry is the length of the key, the key, the length of the value and the value, lengths being
+// uvarints. Each index entry is the length of the block's first key, the key, and the block's offset and length.
+
+// countingWriter tracks the offset in the file being written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// tableWriter writes a table to out. Entries must be added in key order.
+type tableWriter struct {
+	out     *countingWriter
+	block   []byte
+	first   string
+	last    string
+	entries int
+	index   []byte
+}
+
+func (t *tableWriter) add(key string, value []byte) error {
+	if t.entries > 0 && key <= t.last {
+		return fmt.Errorf("snapshot keys must be written in order, but %q came after %q", key, t.last)
+	}
+	if len(t.block) == 0 {
+		t.first = key
+	}
+	t.block = appendBytes(appendString(t.block, key), value)
+	t.last = key
+	t.entries++
+	if len(t.block) >= snapshotBlockSize {
+		return t.flush()
+	}
+	return nil
+}
+
+// flush writes the current block, if it has any entries
+func (t *tableWriter) flush() error {
+	if len(t.block) == 0 {
+		return nil
+	}
+	t.index = appendString(t.index, t.first)
+	t.index = appendUvarint(t.index, uint64(t.out.n))
+	t.index = appendUvarint(t.index, uint64(len(t.block)))
+	_, err := t.out.Write(t.block)
+	t.block = t.block[:0]
+	return err
+}
+
+// blockHandle locates a block of a table in the file
+type blockHandle struct {
+	firstKey string
+	offset   int64
+	length   int64
+}
+
+// table looks keys up in a table, reading the block which could hold each key from r
+type table struct {
+	r      io.ReaderAt
+	blocks []blockHandle
+}
+
+// readTable decodes a table's index. Blocks must lie within the first size bytes of r.
+func readTable(r io.ReaderAt, index []byte, size int64) (*table, error) {
+	t := &table{r: r}
+	d := snapshotDecoder{buf: index}
+	for len(d.buf) > 0 {
+		b := blockHandle{firstKey: d.string(), offset: int64(d.uvarint()), length: int64(d.uvarint())}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if b.offset < 0 || b.offset > size || b.length <= 0 || b.length > size-b.offset ||
+			(len(t.blocks) > 0 && b.firstKey <= t.blocks[len(t.blocks)-1].firstKey) {
+			return nil, errDamagedSnapshot
+		}
+		t.blocks = append(t.blocks, b)
+	}
+	return t, nil
+}
+
+// get returns the value of key, if the table has it
+func (t *table) get(key string) ([]byte, bool, error) {
+	i := sort.Search(len(t.blocks), func(i int) bool { return t.blocks[i].firstKey > key }) - 1
+	if i < 0 {
+		return nil, false, nil
+	}
+	block := make([]byte, t.blocks[i].length)
+	if _, err := t.r.ReadAt(block, t.blocks[i].offset); err != nil {
+		return nil, false, err
+	}
+	d := snapshotDecoder{buf: block}
+	for len(d.buf) > 0 {
+		k, v := d.string(), d.bytes()
+		if d.err != nil {
+			return nil, false, d.err
+		}
+		if k == key {
+			return v, true, nil
+		}
+		if k > key {
+			break
+		}
+	}
+	return nil, false, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	return append(appendUvarint(b, uint64(len(v))), v...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUvarint(b, uint64(len(s))), s...)
+}
+
+func appendStrings(b []byte, list []string) []byte {
+	b = appendUvarint(b, uint64(len(list)))
+	for _, s := range list {
+		b = appendString(b, s)
+	}
+	return b
+}
+
+// snapshotDecoder reads the values appended by appendUvarint and friends from buf, recording the first error
+type snapshotDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *snapshotDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errDamagedSnapshot
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *snapshotDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = errDamagedSnapshot
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *snapshotDecoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *snapshotDecoder) strings() []string {
+	n := d.uvarint()
+	// Each string takes at least a byte, which stops a damaged count allocating a huge slice
+	if d.err == nil && n > uint64(len(d.buf)) {
+		d.err = errDamagedSnapshot
+	}
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	list := make([]string, 0, n)
+	for i := uint64(0); i < n; i++ {
+		list = append(list, d.string())
+	}
+	return list
+}
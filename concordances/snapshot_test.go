@@ -0,0 +1,324 @@
+package concordances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var snapshotTestConcepts = []SnapshotConcept{
+	{
+		PrefUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+		Types:    []string{"Thing", "Concept", "Brand"},
+		Sources: []SnapshotSource{
+			{UUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Authority: "Smartlogic", AuthorityValue: "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+			{UUID: "70f4732b-7f7d-30a1-9c29-0cceec23760e", Authority: "TME", AuthorityValue: "VGhlIFJvbWFu-QnJhbmRz"},
+		},
+	},
+	{
+		PrefUUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115",
+		Types:    []string{"Thing", "Concept", "Organisation"},
+		LEICode:  "5493001KJTIIGC8Y1R12",
+		Sources: []SnapshotSource{
+			{UUID: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", Authority: "Smartlogic", AuthorityValue: "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+			{UUID: "0e86d39b-8320-3a12-8e9e-fd7cc4d6c8c0", Authority: "FACTSET", AuthorityValue: "7IV872-E"},
+		},
+	},
+	{
+		PrefUUID: "82cba3ce-329b-3010-b29d-4282a215889f",
+		Types:    []string{"Thing", "Concept", "Location"},
+		ISO31661: "GB",
+		Sources: []SnapshotSource{
+			{UUID: "82cba3ce-329b-3010-b29d-4282a215889f", Authority: "ManagedLocation", AuthorityValue: "82cba3ce-329b-3010-b29d-4282a215889f"},
+		},
+	},
+}
+
+// writeTestSnapshot writes concepts to path, sorting them by prefUUID as the writer requires
+func writeTestSnapshot(t *testing.T, path string, createdAt time.Time, concepts []SnapshotConcept) {
+	concepts = append([]SnapshotConcept(nil), concepts...)
+	sort.Slice(concepts, func(i, j int) bool { return concepts[i].PrefUUID < concepts[j].PrefUUID })
+	_, err := WriteSnapshotFile(path, createdAt, func(w *SnapshotWriter) error {
+		for _, c := range concepts {
+			if err := w.Write(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func newTestSnapshotDriver(t *testing.T) *SnapshotDriver {
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), snapshotTestConcepts)
+	driver, err := NewSnapshotDriver(path, "prod")
+	require.NoError(t, err)
+	return driver
+}
+
+// identifiersIn lists the authority and value of each identifier in c
+func identifiersIn(c Concordances) [][2]string {
+	var identifiers [][2]string
+	for _, con := range c.Concordance {
+		identifiers = append(identifiers, [2]string{con.Identifier.Authority, con.Identifier.IdentifierValue})
+	}
+	return identifiers
+}
+
+func TestSnapshotDriverReadsByConceptID(t *testing.T) {
+	assert := assert.New(t)
+	driver := newTestSnapshotDriver(t)
+
+	c, found, err := driver.ReadByConceptID(context.Background(), []string{"70f4732b-7f7d-30a1-9c29-0cceec23760e"})
+	require.NoError(t, err)
+	assert.True(found)
+	assert.ElementsMatch([][2]string{
+		{"http://api.ft.com/system/SMARTLOGIC", "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		{"http://api.ft.com/system/FT-TME", "VGhlIFJvbWFu-QnJhbmRz"},
+		{"http://api.ft.com/system/UPP", "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		{"http://api.ft.com/system/UPP", "70f4732b-7f7d-30a1-9c29-0cceec23760e"},
+	}, identifiersIn(c))
+	assert.Equal("http://api.ft.com/things/b20801ac-5a76-43cf-b816-8c3b2f7133ad", c.Concordance[0].Concept.ID)
+	assert.Equal("http://api.ft.com/brands/b20801ac-5a76-43cf-b816-8c3b2f7133ad", c.Concordance[0].Concept.APIURL)
+	assert.Equal([]string{"http://api.ft.com/things/70f4732b-7f7d-30a1-9c29-0cceec23760e"}, c.Concordance[0].Concept.RequestedIDs)
+
+	c, _, err = driver.ReadByConceptID(context.Background(), []string{"cd7e4345-f11f-41f3-a0f0-2cf5c43e0115", "82cba3ce-329b-3010-b29d-4282a215889f"})
+	require.NoError(t, err)
+	assert.Contains(identifiersIn(c), [2]string{"http://api.ft.com/system/LEI", "5493001KJTIIGC8Y1R12"})
+	assert.Contains(identifiersIn(c), [2]string{"http://api.ft.com/system/ISO-3166-1", "GB"})
+
+	_, found, err = driver.ReadByConceptID(context.Background(), []string{"00000000-0000-0000-0000-000000000000"})
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func TestSnapshotDriverReadsByAuthority(t *testing.T) {
+	driver := newTestSnapshotDriver(t)
+	for _, test := range []struct {
+		authority string
+		value     string
+		conceptID string
+	}{
+		{"http://api.ft.com/system/FT-TME", "VGhlIFJvbWFu-QnJhbmRz", "b20801ac-5a76-43cf-b816-8c3b2f7133ad"},
+		{"http://api.ft.com/system/FACTSET", "7IV872-E", "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+		{"http://api.ft.com/system/UPP", "0e86d39b-8320-3a12-8e9e-fd7cc4d6c8c0", "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+		{"http://api.ft.com/system/LEI", "5493001KJTIIGC8Y1R12", "cd7e4345-f11f-41f3-a0f0-2cf5c43e0115"},
+		{"http://api.ft.com/system/ISO-3166-1", "GB", "82cba3ce-329b-3010-b29d-4282a215889f"},
+	} {
+		c, found, err := driver.ReadByAuthority(context.Background(), test.authority, []string{test.value, "unknown"})
+		require.NoError(t, err, test.authority)
+		assert.True(t, found, test.authority)
+		assert.Equal(t, [][2]string{{test.authority, test.value}}, identifiersIn(c), test.authority)
+		assert.Equal(t, "http://api.ft.com/things/"+test.conceptID, c.Concordance[0].Concept.ID, test.authority)
+	}
+
+	_, found, err := driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FT-TME", []string{"7IV872-E"})
+	assert.NoError(t, err)
+	assert.False(t, found, "identifiers are only found in their own authority")
+}
+
+func TestSnapshotDriverRejectsDamagedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Now(), snapshotTestConcepts)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, data[:len(data)/2], 0600))
+	_, err = NewSnapshotDriver(path, "prod")
+	assert.Error(t, err, "a truncated file")
+
+	_, err = NewSnapshotDriver(writeTestFile(t, "concordances.json", `{"concordances": []}`), "prod")
+	assert.Error(t, err, "a file which isn't a snapshot")
+}
+
+func TestSnapshotDriverRejectsChangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Now(), snapshotTestConcepts)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	data[len(snapshotMagic)+10] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	_, err = NewSnapshotDriver(path, "prod")
+	assert.EqualError(t, err, "reading snapshot "+path+": snapshot checksum doesn't match its contents")
+}
+
+func TestSnapshotWriterRejectsConceptsOutOfOrder(t *testing.T) {
+	w, err := NewSnapshotWriter(ioutil.Discard, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, w.Write(snapshotTestConcepts[1]))
+	assert.Error(t, w.Write(snapshotTestConcepts[0]))
+}
+
+func TestSnapshotDriverReadsLookupsFromEveryBlock(t *testing.T) {
+	var concepts []SnapshotConcept
+	for i := 0; i < 2000; i++ {
+		uuid := fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+		concepts = append(concepts, SnapshotConcept{
+			PrefUUID: uuid,
+			Types:    []string{"Thing", "Concept", "Organisation"},
+			Sources:  []SnapshotSource{{UUID: uuid, Authority: "FACTSET", AuthorityValue: fmt.Sprintf("%06d-E", i)}},
+		})
+	}
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Now(), concepts)
+	driver, err := NewSnapshotDriver(path, "prod")
+	require.NoError(t, err)
+	require.Greater(t, len(driver.snapshot.concepts.blocks), 1)
+	require.Greater(t, len(driver.snapshot.lookups.blocks), 1)
+
+	for _, i := range []int{0, 1, 999, 1998, 1999} {
+		c, found, err := driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{fmt.Sprintf("%06d-E", i)})
+		require.NoError(t, err)
+		require.True(t, found, i)
+		assert.Equal(t, "http://api.ft.com/things/"+concepts[i].PrefUUID, c.Concordance[0].Concept.ID)
+	}
+	_, found, err := driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/FACTSET", []string{"002000-E"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestWriteSnapshotManifestFileReplacesTheWholeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "concordances.snapshot.manifest.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"version": 1}`), 0644))
+
+	manifest := SnapshotManifest{Version: snapshotVersion, Concepts: 2, Authorities: map[string]int{"UPP": 2}, SHA256: "abc"}
+	require.NoError(t, WriteSnapshotManifestFile(path, manifest))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var written SnapshotManifest
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, manifest, written)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "no temporary files are left behind")
+}
+
+func TestSnapshotDriverWatchLoadsNewerSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), snapshotTestConcepts[:1])
+	driver, err := NewSnapshotDriver(path, "prod")
+	require.NoError(t, err)
+	stop := driver.Watch(10 * time.Millisecond)
+	defer stop()
+
+	_, found, _ := driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/LEI", []string{"5493001KJTIIGC8Y1R12"})
+	assert.False(t, found)
+
+	// Make sure the modification time changes on filesystems with coarse timestamps
+	writeTestSnapshot(t, path, time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), snapshotTestConcepts)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	assert.Eventually(t, func() bool {
+		return driver.CreatedAt().Equal(time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC))
+	}, time.Second, 10*time.Millisecond)
+	_, found, _ = driver.ReadByAuthority(context.Background(), "http://api.ft.com/system/LEI", []string{"5493001KJTIIGC8Y1R12"})
+	assert.True(t, found)
+}
+
+// pagedNeoConnection answers snapshot page queries from rows, which are sorted by concept
+type pagedNeoConnection struct {
+	fakeNeoConnection
+	rows  []snapshotRow
+	pages int
+}
+
+func (c *pagedNeoConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	c.pages++
+	after, limit := queries[0].Parameters["after"].(string), queries[0].Parameters["limit"].(int)
+	results := queries[0].Result.(*[]snapshotRow)
+	concepts := 0
+	for i, row := range c.rows {
+		if row.CanonicalUUID <= after {
+			continue
+		}
+		if i == 0 || row.CanonicalUUID != c.rows[i-1].CanonicalUUID {
+			if concepts++; concepts > limit {
+				break
+			}
+		}
+		*results = append(*results, row)
+	}
+	return nil
+}
+
+func TestBuildSnapshotPagesThroughEveryConcept(t *testing.T) {
+	assert := assert.New(t)
+	var rows []snapshotRow
+	for _, c := range snapshotTestConcepts {
+		for _, s := range c.Sources {
+			rows = append(rows, snapshotRow{CanonicalUUID: c.PrefUUID, Types: c.Types, LEICode: c.LEICode, ISO31661: c.ISO31661, UUID: s.UUID, Authority: s.Authority, AuthorityValue: s.AuthorityValue})
+		}
+	}
+	// A canonical concept without sources can still be looked up by its LEI code
+	rows = append(rows, snapshotRow{CanonicalUUID: "f2f1d5a6-0000-4c47-9a2a-6d7e8b1c1d1e", Types: []string{"Thing", "Concept", "Organisation"}, LEICode: "213800ABCDEFGHIJKL12"})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].CanonicalUUID < rows[j].CanonicalUUID })
+	conn := &pagedNeoConnection{rows: rows}
+
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	manifest, err := WriteSnapshotFile(path, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), func(w *SnapshotWriter) error {
+		return NewCypherDriver(conn, "prod").BuildSnapshot(context.Background(), w, 2)
+	})
+	require.NoError(t, err)
+	assert.Equal(3, conn.pages, "two pages of two concepts, and an empty one")
+	assert.Equal(4, manifest.Concepts)
+	assert.Equal(map[string]int{"Smartlogic": 2, "TME": 1, "FACTSET": 1, "ManagedLocation": 1, "UPP": 5, "LEI": 2, "ISO-3166-1": 1}, manifest.Authorities)
+
+	driver, err := NewSnapshotDriver(path, "prod")
+	require.NoError(t, err)
+	for authority, value := range map[string]string{
+		"http://api.ft.com/system/LEI":        "213800ABCDEFGHIJKL12",
+		"http://api.ft.com/system/ISO-3166-1": "GB",
+		"http://api.ft.com/system/FT-TME":     "VGhlIFJvbWFu-QnJhbmRz",
+	} {
+		_, found, err := driver.ReadByAuthority(context.Background(), authority, []string{value})
+		assert.NoError(err)
+		assert.True(found, authority)
+	}
+}
+
+func TestBuildSnapshotRejectsNonPositivePageSizes(t *testing.T) {
+	w, err := NewSnapshotWriter(ioutil.Discard, time.Now())
+	require.NoError(t, err)
+	assert.Error(t, NewCypherDriver(&pagedNeoConnection{}, "prod").BuildSnapshot(context.Background(), w, 0))
+}
+
+func TestHandlersConcordTheirOwnAuthorities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concordances.snapshot")
+	writeTestSnapshot(t, path, time.Now(), []SnapshotConcept{{
+		PrefUUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad",
+		Types:    []string{"Thing", "Concept", "Person"},
+		Sources:  []SnapshotSource{{UUID: "b20801ac-5a76-43cf-b816-8c3b2f7133ad", Authority: "ORCID", AuthorityValue: "0000-0002-1825-0097"}},
+	}})
+	driver, err := NewSnapshotDriver(path, "prod")
+	require.NoError(t, err)
+
+	concordancesFrom := func(s *httptest.Server) []Concordance {
+		res, err := http.Get(s.URL + "/concordances?authority=http://api.ft.com/system/ORCID&identifierValue=0000-0002-1825-0097")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var c Concordances
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&c))
+		return c.Concordance
+	}
+	withORCID := newTestServer(t, driver, WithAuthorities(map[string]string{"ORCID": "http://api.ft.com/system/ORCID"}))
+	assert.Len(t, concordancesFrom(withORCID), 1)
+	assert.Empty(t, concordancesFrom(newTestServer(t, driver)), "the other handler only knows the built in authorities")
+}
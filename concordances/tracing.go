@@ -0,0 +1,21 @@
+package concordances
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Financial-Times/public-concordances-api/concordances"
+
+// tracer is resolved through the global provider, so spans are no-ops until main installs an exporter
+var tracer = otel.Tracer(instrumentationName)
+
+// endSpan records err on the span, if there is one, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,49 @@
+package concordances
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+)
+
+// watchFile calls reload whenever the modification time of the file at path changes, checking every interval, and
+// whenever the process receives a SIGHUP, until stopped. Mounted ConfigMaps are updated by swapping a symlink, so the
+// file is polled rather than watched for filesystem events.
+func watchFile(path string, interval time.Duration, reload func()) (stop func()) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				log.WithField("path", path).Info("Received SIGHUP, reloading")
+				reload()
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					log.WithError(err).WithField("path", path).Error("Failed to check file for changes")
+					continue
+				}
+				if !info.ModTime().Equal(modTime) {
+					modTime = info.ModTime()
+					reload()
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,575 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v4.25.3
+// source: concordances.proto
+
+package concordancespb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ConceptConcordancesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// UUIDs or http://api.ft.com/things/ URIs
+	ConceptIds []string `protobuf:"bytes,1,rep,name=concept_ids,json=conceptIds,proto3" json:"concept_ids,omitempty"`
+}
+
+func (x *ConceptConcordancesRequest) Reset() {
+	*x = ConceptConcordancesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConceptConcordancesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConceptConcordancesRequest) ProtoMessage() {}
+
+func (x *ConceptConcordancesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConceptConcordancesRequest.ProtoReflect.Descriptor instead.
+func (*ConceptConcordancesRequest) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ConceptConcordancesRequest) GetConceptIds() []string {
+	if x != nil {
+		return x.ConceptIds
+	}
+	return nil
+}
+
+type AuthorityConcordancesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Authority URI, e.g. http://api.ft.com/system/FT-TME
+	Authority        string   `protobuf:"bytes,1,opt,name=authority,proto3" json:"authority,omitempty"`
+	IdentifierValues []string `protobuf:"bytes,2,rep,name=identifier_values,json=identifierValues,proto3" json:"identifier_values,omitempty"`
+}
+
+func (x *AuthorityConcordancesRequest) Reset() {
+	*x = AuthorityConcordancesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthorityConcordancesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorityConcordancesRequest) ProtoMessage() {}
+
+func (x *AuthorityConcordancesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorityConcordancesRequest.ProtoReflect.Descriptor instead.
+func (*AuthorityConcordancesRequest) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AuthorityConcordancesRequest) GetAuthority() string {
+	if x != nil {
+		return x.Authority
+	}
+	return ""
+}
+
+func (x *AuthorityConcordancesRequest) GetIdentifierValues() []string {
+	if x != nil {
+		return x.IdentifierValues
+	}
+	return nil
+}
+
+type ConcordancesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Concordances []*Concordance `protobuf:"bytes,1,rep,name=concordances,proto3" json:"concordances,omitempty"`
+}
+
+func (x *ConcordancesResponse) Reset() {
+	*x = ConcordancesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConcordancesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConcordancesResponse) ProtoMessage() {}
+
+func (x *ConcordancesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConcordancesResponse.ProtoReflect.Descriptor instead.
+func (*ConcordancesResponse) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ConcordancesResponse) GetConcordances() []*Concordance {
+	if x != nil {
+		return x.Concordances
+	}
+	return nil
+}
+
+type Concordance struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Concept    *Concept    `protobuf:"bytes,1,opt,name=concept,proto3" json:"concept,omitempty"`
+	Identifier *Identifier `protobuf:"bytes,2,opt,name=identifier,proto3" json:"identifier,omitempty"`
+}
+
+func (x *Concordance) Reset() {
+	*x = Concordance{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Concordance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Concordance) ProtoMessage() {}
+
+func (x *Concordance) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Concordance.ProtoReflect.Descriptor instead.
+func (*Concordance) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Concordance) GetConcept() *Concept {
+	if x != nil {
+		return x.Concept
+	}
+	return nil
+}
+
+func (x *Concordance) GetIdentifier() *Identifier {
+	if x != nil {
+		return x.Identifier
+	}
+	return nil
+}
+
+type Concept struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ApiUrl string `protobuf:"bytes,2,opt,name=api_url,json=apiUrl,proto3" json:"api_url,omitempty"`
+	// Non-canonical IDs in the request that resolved to this concept
+	RequestedIds []string `protobuf:"bytes,3,rep,name=requested_ids,json=requestedIds,proto3" json:"requested_ids,omitempty"`
+	// Requested IDs of concepts that were merged into this one
+	MergedFrom []string `protobuf:"bytes,4,rep,name=merged_from,json=mergedFrom,proto3" json:"merged_from,omitempty"`
+}
+
+func (x *Concept) Reset() {
+	*x = Concept{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Concept) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Concept) ProtoMessage() {}
+
+func (x *Concept) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Concept.ProtoReflect.Descriptor instead.
+func (*Concept) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Concept) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Concept) GetApiUrl() string {
+	if x != nil {
+		return x.ApiUrl
+	}
+	return ""
+}
+
+func (x *Concept) GetRequestedIds() []string {
+	if x != nil {
+		return x.RequestedIds
+	}
+	return nil
+}
+
+func (x *Concept) GetMergedFrom() []string {
+	if x != nil {
+		return x.MergedFrom
+	}
+	return nil
+}
+
+type Identifier struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Authority       string `protobuf:"bytes,1,opt,name=authority,proto3" json:"authority,omitempty"`
+	IdentifierValue string `protobuf:"bytes,2,opt,name=identifier_value,json=identifierValue,proto3" json:"identifier_value,omitempty"`
+}
+
+func (x *Identifier) Reset() {
+	*x = Identifier{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_concordances_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Identifier) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Identifier) ProtoMessage() {}
+
+func (x *Identifier) ProtoReflect() protoreflect.Message {
+	mi := &file_concordances_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Identifier.ProtoReflect.Descriptor instead.
+func (*Identifier) Descriptor() ([]byte, []int) {
+	return file_concordances_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Identifier) GetAuthority() string {
+	if x != nil {
+		return x.Authority
+	}
+	return ""
+}
+
+func (x *Identifier) GetIdentifierValue() string {
+	if x != nil {
+		return x.IdentifierValue
+	}
+	return ""
+}
+
+var File_concordances_proto protoreflect.FileDescriptor
+
+var file_concordances_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x12, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64,
+	0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x22, 0x3d, 0x0a, 0x1a, 0x43, 0x6f, 0x6e, 0x63,
+	0x65, 0x70, 0x74, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x63, 0x65, 0x70,
+	0x74, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6e,
+	0x63, 0x65, 0x70, 0x74, 0x49, 0x64, 0x73, 0x22, 0x69, 0x0a, 0x1c, 0x41, 0x75, 0x74, 0x68, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61, 0x75, 0x74, 0x68,
+	0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x2b, 0x0a, 0x11, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66,
+	0x69, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x10, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x73, 0x22, 0x5b, 0x0a, 0x14, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63,
+	0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x0c, 0x63, 0x6f,
+	0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1f, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63,
+	0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63,
+	0x65, 0x52, 0x0c, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x22,
+	0x84, 0x01, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x12,
+	0x35, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1b, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63,
+	0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x52, 0x07, 0x63,
+	0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x12, 0x3e, 0x0a, 0x0a, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x66, 0x69, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x66, 0x74, 0x2e,
+	0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x52, 0x0a, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x22, 0x78, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x63, 0x65, 0x70,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x70, 0x69, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x61, 0x70, 0x69, 0x55, 0x72, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x0c, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x49, 0x64, 0x73, 0x12,
+	0x1f, 0x0a, 0x0b, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x04,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x46, 0x72, 0x6f, 0x6d,
+	0x22, 0x55, 0x0a, 0x0a, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x1c,
+	0x0a, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x29, 0x0a, 0x10,
+	0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69,
+	0x65, 0x72, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x32, 0xde, 0x03, 0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x63,
+	0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x72, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x43,
+	0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63,
+	0x65, 0x73, 0x12, 0x2e, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61,
+	0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x43,
+	0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x28, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61,
+	0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61,
+	0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x76, 0x0a, 0x18,
+	0x47, 0x65, 0x74, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x63,
+	0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x30, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f,
+	0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75,
+	0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e,
+	0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x66, 0x74, 0x2e,
+	0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x6e, 0x0a, 0x19, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x43, 0x6f,
+	0x6e, 0x63, 0x65, 0x70, 0x74, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65,
+	0x73, 0x12, 0x2e, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e,
+	0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x65, 0x70, 0x74, 0x43, 0x6f,
+	0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1f, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e,
+	0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e,
+	0x63, 0x65, 0x30, 0x01, 0x12, 0x72, 0x0a, 0x1b, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x41, 0x75,
+	0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e,
+	0x63, 0x65, 0x73, 0x12, 0x30, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64,
+	0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
+	0x74, 0x79, 0x43, 0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x66, 0x74, 0x2e, 0x63, 0x6f, 0x6e, 0x63, 0x6f,
+	0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x6f,
+	0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x30, 0x01, 0x42, 0x43, 0x5a, 0x41, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x46, 0x69, 0x6e, 0x61, 0x6e, 0x63, 0x69, 0x61, 0x6c,
+	0x2d, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x2f, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x2d, 0x63, 0x6f,
+	0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x63,
+	0x6f, 0x6e, 0x63, 0x6f, 0x72, 0x64, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_concordances_proto_rawDescOnce sync.Once
+	file_concordances_proto_rawDescData = file_concordances_proto_rawDesc
+)
+
+func file_concordances_proto_rawDescGZIP() []byte {
+	file_concordances_proto_rawDescOnce.Do(func() {
+		file_concordances_proto_rawDescData = protoimpl.X.CompressGZIP(file_concordances_proto_rawDescData)
+	})
+	return file_concordances_proto_rawDescData
+}
+
+var file_concordances_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_concordances_proto_goTypes = []any{
+	(*ConceptConcordancesRequest)(nil),   // 0: ft.concordances.v1.ConceptConcordancesRequest
+	(*AuthorityConcordancesRequest)(nil), // 1: ft.concordances.v1.AuthorityConcordancesRequest
+	(*ConcordancesResponse)(nil),         // 2: ft.concordances.v1.ConcordancesResponse
+	(*Concordance)(nil),                  // 3: ft.concordances.v1.Concordance
+	(*Concept)(nil),                      // 4: ft.concordances.v1.Concept
+	(*Identifier)(nil),                   // 5: ft.concordances.v1.Identifier
+}
+var file_concordances_proto_depIdxs = []int32{
+	3, // 0: ft.concordances.v1.ConcordancesResponse.concordances:type_name -> ft.concordances.v1.Concordance
+	4, // 1: ft.concordances.v1.Concordance.concept:type_name -> ft.concordances.v1.Concept
+	5, // 2: ft.concordances.v1.Concordance.identifier:type_name -> ft.concordances.v1.Identifier
+	0, // 3: ft.concordances.v1.Concordances.GetConceptConcordances:input_type -> ft.concordances.v1.ConceptConcordancesRequest
+	1, // 4: ft.concordances.v1.Concordances.GetAuthorityConcordances:input_type -> ft.concordances.v1.AuthorityConcordancesRequest
+	0, // 5: ft.concordances.v1.Concordances.StreamConceptConcordances:input_type -> ft.concordances.v1.ConceptConcordancesRequest
+	1, // 6: ft.concordances.v1.Concordances.StreamAuthorityConcordances:input_type -> ft.concordances.v1.AuthorityConcordancesRequest
+	2, // 7: ft.concordances.v1.Concordances.GetConceptConcordances:output_type -> ft.concordances.v1.ConcordancesResponse
+	2, // 8: ft.concordances.v1.Concordances.GetAuthorityConcordances:output_type -> ft.concordances.v1.ConcordancesResponse
+	3, // 9: ft.concordances.v1.Concordances.StreamConceptConcordances:output_type -> ft.concordances.v1.Concordance
+	3, // 10: ft.concordances.v1.Concordances.StreamAuthorityConcordances:output_type -> ft.concordances.v1.Concordance
+	7, // [7:11] is the sub-list for method output_type
+	3, // [3:7] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_concordances_proto_init() }
+func file_concordances_proto_init() {
+	if File_concordances_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_concordances_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ConceptConcordancesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_concordances_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthorityConcordancesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_concordances_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ConcordancesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_concordances_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*Concordance); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_concordances_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*Concept); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_concordances_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*Identifier); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_concordances_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_concordances_proto_goTypes,
+		DependencyIndexes: file_concordances_proto_depIdxs,
+		MessageInfos:      file_concordances_proto_msgTypes,
+	}.Build()
+	File_concordances_proto = out.File
+	file_concordances_proto_rawDesc = nil
+	file_concordances_proto_goTypes = nil
+	file_concordances_proto_depIdxs = nil
+}
@@ -0,0 +1,293 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v4.25.3
+// source: concordances.proto
+
+package concordancespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Concordances_GetConceptConcordances_FullMethodName      = "/ft.concordances.v1.Concordances/GetConceptConcordances"
+	Concordances_GetAuthorityConcordances_FullMethodName    = "/ft.concordances.v1.Concordances/GetAuthorityConcordances"
+	Concordances_StreamConceptConcordances_FullMethodName   = "/ft.concordances.v1.Concordances/StreamConceptConcordances"
+	Concordances_StreamAuthorityConcordances_FullMethodName = "/ft.concordances.v1.Concordances/StreamAuthorityConcordances"
+)
+
+// ConcordancesClient is the client API for Concordances service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Concordances looks up the identifiers of concepts, and the concepts behind identifiers, without the cost of
+// encoding and parsing JSON. It is backed by the same queries as the REST API.
+type ConcordancesClient interface {
+	// GetConceptConcordances returns every identifier of each requested concept
+	GetConceptConcordances(ctx context.Context, in *ConceptConcordancesRequest, opts ...grpc.CallOption) (*ConcordancesResponse, error)
+	// GetAuthorityConcordances returns the concepts for identifier values in a single authority
+	GetAuthorityConcordances(ctx context.Context, in *AuthorityConcordancesRequest, opts ...grpc.CallOption) (*ConcordancesResponse, error)
+	// StreamConceptConcordances sends the same concordances as GetConceptConcordances, one message each
+	StreamConceptConcordances(ctx context.Context, in *ConceptConcordancesRequest, opts ...grpc.CallOption) (Concordances_StreamConceptConcordancesClient, error)
+	// StreamAuthorityConcordances sends the same concordances as GetAuthorityConcordances, one message each
+	StreamAuthorityConcordances(ctx context.Context, in *AuthorityConcordancesRequest, opts ...grpc.CallOption) (Concordances_StreamAuthorityConcordancesClient, error)
+}
+
+type concordancesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConcordancesClient(cc grpc.ClientConnInterface) ConcordancesClient {
+	return &concordancesClient{cc}
+}
+
+func (c *concordancesClient) GetConceptConcordances(ctx context.Context, in *ConceptConcordancesRequest, opts ...grpc.CallOption) (*ConcordancesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConcordancesResponse)
+	err := c.cc.Invoke(ctx, Concordances_GetConceptConcordances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *concordancesClient) GetAuthorityConcordances(ctx context.Context, in *AuthorityConcordancesRequest, opts ...grpc.CallOption) (*ConcordancesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConcordancesResponse)
+	err := c.cc.Invoke(ctx, Concordances_GetAuthorityConcordances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *concordancesClient) StreamConceptConcordances(ctx context.Context, in *ConceptConcordancesRequest, opts ...grpc.CallOption) (Concordances_StreamConceptConcordancesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Concordances_ServiceDesc.Streams[0], Concordances_StreamConceptConcordances_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &concordancesStreamConceptConcordancesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Concordances_StreamConceptConcordancesClient interface {
+	Recv() (*Concordance, error)
+	grpc.ClientStream
+}
+
+type concordancesStreamConceptConcordancesClient struct {
+	grpc.ClientStream
+}
+
+func (x *concordancesStreamConceptConcordancesClient) Recv() (*Concordance, error) {
+	m := new(Concordance)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *concordancesClient) StreamAuthorityConcordances(ctx context.Context, in *AuthorityConcordancesRequest, opts ...grpc.CallOption) (Concordances_StreamAuthorityConcordancesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Concordances_ServiceDesc.Streams[1], Concordances_StreamAuthorityConcordances_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &concordancesStreamAuthorityConcordancesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Concordances_StreamAuthorityConcordancesClient interface {
+	Recv() (*Concordance, error)
+	grpc.ClientStream
+}
+
+type concordancesStreamAuthorityConcordancesClient struct {
+	grpc.ClientStream
+}
+
+func (x *concordancesStreamAuthorityConcordancesClient) Recv() (*Concordance, error) {
+	m := new(Concordance)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ConcordancesServer is the server API for Concordances service.
+// All implementations must embed UnimplementedConcordancesServer
+// for forward compatibility
+//
+// Concordances looks up the identifiers of concepts, and the concepts behind identifiers, without the cost of
+// encoding and parsing JSON. It is backed by the same queries as the REST API.
+type ConcordancesServer interface {
+	// GetConceptConcordances returns every identifier of each requested concept
+	GetConceptConcordances(context.Context, *ConceptConcordancesRequest) (*ConcordancesResponse, error)
+	// GetAuthorityConcordances returns the concepts for identifier values in a single authority
+	GetAuthorityConcordances(context.Context, *AuthorityConcordancesRequest) (*ConcordancesResponse, error)
+	// StreamConceptConcordances sends the same concordances as GetConceptConcordances, one message each
+	StreamConceptConcordances(*ConceptConcordancesRequest, Concordances_StreamConceptConcordancesServer) error
+	// StreamAuthorityConcordances sends the same concordances as GetAuthorityConcordances, one message each
+	StreamAuthorityConcordances(*AuthorityConcordancesRequest, Concordances_StreamAuthorityConcordancesServer) error
+	mustEmbedUnimplementedConcordancesServer()
+}
+
+// UnimplementedConcordancesServer must be embedded to have forward compatible implementations.
+type UnimplementedConcordancesServer struct {
+}
+
+func (UnimplementedConcordancesServer) GetConceptConcordances(context.Context, *ConceptConcordancesRequest) (*ConcordancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConceptConcordances not implemented")
+}
+func (UnimplementedConcordancesServer) GetAuthorityConcordances(context.Context, *AuthorityConcordancesRequest) (*ConcordancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAuthorityConcordances not implemented")
+}
+func (UnimplementedConcordancesServer) StreamConceptConcordances(*ConceptConcordancesRequest, Concordances_StreamConceptConcordancesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamConceptConcordances not implemented")
+}
+func (UnimplementedConcordancesServer) StreamAuthorityConcordances(*AuthorityConcordancesRequest, Concordances_StreamAuthorityConcordancesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamAuthorityConcordances not implemented")
+}
+func (UnimplementedConcordancesServer) mustEmbedUnimplementedConcordancesServer() {}
+
+// UnsafeConcordancesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConcordancesServer will
+// result in compilation errors.
+type UnsafeConcordancesServer interface {
+	mustEmbedUnimplementedConcordancesServer()
+}
+
+func RegisterConcordancesServer(s grpc.ServiceRegistrar, srv ConcordancesServer) {
+	s.RegisterService(&Concordances_ServiceDesc, srv)
+}
+
+func _Concordances_GetConceptConcordances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConceptConcordancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConcordancesServer).GetConceptConcordances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Concordances_GetConceptConcordances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConcordancesServer).GetConceptConcordances(ctx, req.(*ConceptConcordancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Concordances_GetAuthorityConcordances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthorityConcordancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConcordancesServer).GetAuthorityConcordances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Concordances_GetAuthorityConcordances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConcordancesServer).GetAuthorityConcordances(ctx, req.(*AuthorityConcordancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Concordances_StreamConceptConcordances_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ConceptConcordancesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConcordancesServer).StreamConceptConcordances(m, &concordancesStreamConceptConcordancesServer{ServerStream: stream})
+}
+
+type Concordances_StreamConceptConcordancesServer interface {
+	Send(*Concordance) error
+	grpc.ServerStream
+}
+
+type concordancesStreamConceptConcordancesServer struct {
+	grpc.ServerStream
+}
+
+func (x *concordancesStreamConceptConcordancesServer) Send(m *Concordance) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Concordances_StreamAuthorityConcordances_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AuthorityConcordancesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConcordancesServer).StreamAuthorityConcordances(m, &concordancesStreamAuthorityConcordancesServer{ServerStream: stream})
+}
+
+type Concordances_StreamAuthorityConcordancesServer interface {
+	Send(*Concordance) error
+	grpc.ServerStream
+}
+
+type concordancesStreamAuthorityConcordancesServer struct {
+	grpc.ServerStream
+}
+
+func (x *concordancesStreamAuthorityConcordancesServer) Send(m *Concordance) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Concordances_ServiceDesc is the grpc.ServiceDesc for Concordances service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Concordances_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ft.concordances.v1.Concordances",
+	HandlerType: (*ConcordancesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConceptConcordances",
+			Handler:    _Concordances_GetConceptConcordances_Handler,
+		},
+		{
+			MethodName: "GetAuthorityConcordances",
+			Handler:    _Concordances_GetAuthorityConcordances_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamConceptConcordances",
+			Handler:       _Concordances_StreamConceptConcordances_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamAuthorityConcordances",
+			Handler:       _Concordances_StreamAuthorityConcordances_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "concordances.proto",
+}
@@ -0,0 +1,4 @@
+// Package concordancespb holds the gRPC service definition for concordances and the code generated from it
+package concordancespb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative concordances.proto
@@ -0,0 +1,61 @@
+module github.com/Financial-Times/public-concordances-api
+
+go 1.21
+
+// The Financial-Times fork of neoism, as dep used
+replace github.com/jmcvetta/neoism => github.com/Financial-Times/neoism 0a3ba1ab89c4
+
+require (
+	github.com/Financial-Times/concepts-rw-neo4j a484da9db19a
+	github.com/Financial-Times/go-fthealth 1b007e2b37b7
+	github.com/Financial-Times/go-logger febee6537e90
+	github.com/Financial-Times/http-handlers-go 2c20324ab887
+	github.com/Financial-Times/neo-model-utils-go aea1e95c8305
+	github.com/Financial-Times/neo-utils-go 1fe6ae2f38f3
+	github.com/Financial-Times/service-status-go 3f5199736a3d
+	github.com/Financial-Times/transactionid-utils-go v0.2.0
+	github.com/getkin/kin-openapi v0.122.0
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/gorilla/mux v1.8.0
+	github.com/graph-gophers/graphql-go v1.5.0
+	github.com/jawher/mow.cli v1.0.5
+	github.com/jmcvetta/neoism 0a3ba1ab89c4
+	github.com/joho/godotenv v1.3.0
+	github.com/rcrowley/go-metrics v0.0.0-20181016184325-3113b8401b8a
+	github.com/sirupsen/logrus v1.4.1
+	github.com/stretchr/testify v1.9.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+	golang.org/x/time v0.5.0
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/go-openapi/jsonpointer v0.19.6 // indirect
+	github.com/go-openapi/swag v0.22.4 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.20.0 // indirect
+	github.com/invopop/yaml v0.2.0 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/perimeterx/marshmallow v1.1.5 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.28.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.3.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20240701130421-f6361c86f094 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240701130421-f6361c86f094 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
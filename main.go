@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
@@ -13,7 +18,6 @@ import (
 	"github.com/Financial-Times/neo-utils-go/neoutils"
 	"github.com/Financial-Times/public-concordances-api/concordances"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/jawher/mow.cli"
 	_ "github.com/joho/godotenv/autoload"
@@ -32,15 +36,147 @@ func main() {
 	neoURL := app.String(cli.StringOpt{
 		Name:   "neo-url",
 		Value:  "http://localhost:7474/db/data",
-		Desc:   "neo4j endpoint URL",
+		Desc:   "neo4j endpoint URL, or a comma separated list of URLs to spread read queries across. The first must be writable to CREATE_MISSING_INDEXES",
 		EnvVar: "NEO_URL",
 	})
+	neoRouting := app.String(cli.StringOpt{
+		Name:   "neo-routing",
+		Value:  string(concordances.RoundRobin),
+		Desc:   "How queries are spread across several NEO_URLs: round-robin, or least-latency to prefer the fastest",
+		EnvVar: "NEO_ROUTING",
+	})
+	neoEjectAfter := app.Int(cli.IntOpt{
+		Name:   "neo-eject-after",
+		Value:  3,
+		Desc:   "Consecutive failed queries after which one of several NEO_URLs stops being queried",
+		EnvVar: "NEO_EJECT_AFTER",
+	})
+	neoEjectFor := app.String(cli.StringOpt{
+		Name:   "neo-eject-for",
+		Value:  "30s",
+		Desc:   "How long a failing NEO_URL is ejected for. It is restored sooner if its healthcheck passes",
+		EnvVar: "NEO_EJECT_FOR",
+	})
 	port := app.String(cli.StringOpt{
 		Name:   "port",
 		Value:  "8080",
 		Desc:   "Port to listen on",
 		EnvVar: "APP_PORT",
 	})
+	grpcPort := app.String(cli.StringOpt{
+		Name:   "grpc-port",
+		Value:  "8081",
+		Desc:   "Port to serve the gRPC API on",
+		EnvVar: "GRPC_PORT",
+	})
+	authClientsFile := app.String(cli.StringOpt{
+		Name:   "auth-clients-file",
+		Value:  "",
+		Desc:   "JSON file of the clients allowed to call the API, with their API keys and allowed authorities. Requests are not authenticated if unset",
+		EnvVar: "AUTH_CLIENTS_FILE",
+	})
+	jwksFile := app.String(cli.StringOpt{
+		Name:   "jwks-file",
+		Value:  "",
+		Desc:   "JWKS file of the keys JWT bearer tokens are verified against. The token subject must be a client in AUTH_CLIENTS_FILE",
+		EnvVar: "JWKS_FILE",
+	})
+	jwtIssuer := app.String(cli.StringOpt{
+		Name:   "jwt-issuer",
+		Value:  "",
+		Desc:   "Required issuer of JWT bearer tokens, if set",
+		EnvVar: "JWT_ISSUER",
+	})
+	jwtAudience := app.String(cli.StringOpt{
+		Name:   "jwt-audience",
+		Value:  "",
+		Desc:   "Required audience of JWT bearer tokens, if set",
+		EnvVar: "JWT_AUDIENCE",
+	})
+	visibilityPolicyFile := app.String(cli.StringOpt{
+		Name:   "visibility-policy-file",
+		Value:  "",
+		Desc:   "JSON file restricting licensed authorities to entitled callers. Every authority is visible to every caller if unset",
+		EnvVar: "VISIBILITY_POLICY_FILE",
+	})
+	policyReloadInterval := app.String(cli.StringOpt{
+		Name:   "policy-reload-interval",
+		Value:  "30s",
+		Desc:   "How often to check VISIBILITY_POLICY_FILE for changes",
+		EnvVar: "POLICY_RELOAD_INTERVAL",
+	})
+	configFile := app.String(cli.StringOpt{
+		Name:   "config-file",
+		Value:  "",
+		Desc:   "JSON file of settings to change without a restart: cacheDuration, logLevel, authorities, rateLimit and rateLimitBurst. Overrides the corresponding flags",
+		EnvVar: "CONFIG_FILE",
+	})
+	configReloadInterval := app.String(cli.StringOpt{
+		Name:   "config-reload-interval",
+		Value:  "30s",
+		Desc:   "How often to check CONFIG_FILE for changes. It is also reloaded on SIGHUP",
+		EnvVar: "CONFIG_RELOAD_INTERVAL",
+	})
+	rateLimit := app.Int(cli.IntOpt{
+		Name:   "rate-limit",
+		Value:  50,
+		Desc:   "Requests per second allowed for each client, or each IP address if requests aren't authenticated. 0 disables rate limiting",
+		EnvVar: "RATE_LIMIT",
+	})
+	rateLimitBurst := app.Int(cli.IntOpt{
+		Name:   "rate-limit-burst",
+		Value:  100,
+		Desc:   "Requests a client can make at once before being held to RATE_LIMIT",
+		EnvVar: "RATE_LIMIT_BURST",
+	})
+	maxConcurrentQueries := app.Int(cli.IntOpt{
+		Name:   "max-concurrent-queries",
+		Value:  32,
+		Desc:   "Neo4j queries run at once across all requests. 0 is unlimited",
+		EnvVar: "MAX_CONCURRENT_QUERIES",
+	})
+	maxQueuedQueries := app.Int(cli.IntOpt{
+		Name:   "max-queued-queries",
+		Value:  256,
+		Desc:   "Queries waiting for one of MAX_CONCURRENT_QUERIES before further requests are rejected with a 503",
+		EnvVar: "MAX_QUEUED_QUERIES",
+	})
+	queryQueueTimeout := app.String(cli.StringOpt{
+		Name:   "query-queue-timeout",
+		Value:  "2s",
+		Desc:   "How long a query waits in the queue before its request is rejected with a 503",
+		EnvVar: "QUERY_QUEUE_TIMEOUT",
+	})
+	fallbackNeoURL := app.String(cli.StringOpt{
+		Name:   "fallback-neo-url",
+		Value:  "",
+		Desc:   "neo4j endpoint URL of a read replica to answer from when NEO_URL is failing. If unset, recent responses are answered from a stale cache instead",
+		EnvVar: "FALLBACK_NEO_URL",
+	})
+	staleCacheEntries := app.Int(cli.IntOpt{
+		Name:   "stale-cache-entries",
+		Value:  10000,
+		Desc:   "Recent lookups kept to answer from when NEO_URL is failing and there is no FALLBACK_NEO_URL. 0 disables the fallback",
+		EnvVar: "STALE_CACHE_ENTRIES",
+	})
+	breakerThreshold := app.Int(cli.IntOpt{
+		Name:   "breaker-threshold",
+		Value:  5,
+		Desc:   "Consecutive failed queries after which NEO_URL is no longer queried and every request is answered from the fallback",
+		EnvVar: "BREAKER_THRESHOLD",
+	})
+	breakerOpenDuration := app.String(cli.StringOpt{
+		Name:   "breaker-open-duration",
+		Value:  "30s",
+		Desc:   "How long to answer from the fallback before trying NEO_URL again",
+		EnvVar: "BREAKER_OPEN_DURATION",
+	})
+	maxIDsPerRequest := app.Int(cli.IntOpt{
+		Name:   "max-ids-per-request",
+		Value:  500,
+		Desc:   "Most conceptId or identifierValue parameters accepted in one request. 0 is unlimited",
+		EnvVar: "MAX_IDS_PER_REQUEST",
+	})
 	env := app.String(cli.StringOpt{
 		Name:  "env",
 		Value: "local",
@@ -70,64 +206,272 @@ func main() {
 		Desc:   "Max batch size for Neo4j queries",
 		EnvVar: "BATCH_SIZE",
 	})
+	slowQueryThreshold := app.String(cli.StringOpt{
+		Name:   "slow-query-threshold",
+		Value:  "2s",
+		Desc:   "Cypher queries taking longer than this are logged. 0 disables the slow query log",
+		EnvVar: "SLOW_QUERY_THRESHOLD",
+	})
+	profileSlowQueries := app.Bool(cli.BoolOpt{
+		Name:   "profile-slow-queries",
+		Value:  false,
+		Desc:   "Re-run slow queries with PROFILE and log their plan. For debugging only, as it doubles the cost of slow queries",
+		EnvVar: "PROFILE_SLOW_QUERIES",
+	})
+	snapshotFile := app.String(cli.StringOpt{
+		Name:   "snapshot-file",
+		Value:  "",
+		Desc:   "Serve concordances from a snapshot file made by `snapshot build` rather than from Neo4j, whose settings are then ignored",
+		EnvVar: "SNAPSHOT_FILE",
+	})
+	snapshotReloadInterval := app.String(cli.StringOpt{
+		Name:   "snapshot-reload-interval",
+		Value:  "1m",
+		Desc:   "How often the snapshot file is checked for a newer snapshot",
+		EnvVar: "SNAPSHOT_RELOAD_INTERVAL",
+	})
+	neoMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "neo-max-attempts",
+		Value:  3,
+		Desc:   "How many times a Cypher query failing with a transient error, such as a dropped connection, is tried. 1 disables retries",
+		EnvVar: "NEO_MAX_ATTEMPTS",
+	})
+	neoRetryBackoff := app.String(cli.StringOpt{
+		Name:   "neo-retry-backoff",
+		Value:  "50ms",
+		Desc:   "Longest wait before retrying a Cypher query, which doubles with each retry",
+		EnvVar: "NEO_RETRY_BACKOFF",
+	})
+	neoRetryMaxBackoff := app.String(cli.StringOpt{
+		Name:   "neo-retry-max-backoff",
+		Value:  "1s",
+		Desc:   "Cap on the wait between retries of a Cypher query",
+		EnvVar: "NEO_RETRY_MAX_BACKOFF",
+	})
+	createMissingIndexes := app.Bool(cli.BoolOpt{
+		Name:   "create-missing-indexes",
+		Value:  false,
+		Desc:   "Create any Neo4j index the concordance queries rely on that is missing at startup. Needs a writable Neo4j URL",
+		EnvVar: "CREATE_MISSING_INDEXES",
+	})
+	debugPayloadSampleRate := app.Int(cli.IntOpt{
+		Name:   "debug-payload-sample-rate",
+		Value:  100,
+		Desc:   "At debug log level, log 1 in every N large response bodies",
+		EnvVar: "DEBUG_PAYLOAD_SAMPLE_RATE",
+	})
+	tracingEnabled := app.Bool(cli.BoolOpt{
+		Name:   "tracing-enabled",
+		Value:  false,
+		Desc:   "Export OpenTelemetry traces to an OTLP collector",
+		EnvVar: "TRACING_ENABLED",
+	})
+	otlpEndpoint := app.String(cli.StringOpt{
+		Name:   "otlp-endpoint",
+		Value:  "localhost:4318",
+		Desc:   "host:port of the OTLP/HTTP collector traces are exported to",
+		EnvVar: "OTLP_ENDPOINT",
+	})
+	otlpInsecure := app.Bool(cli.BoolOpt{
+		Name:   "otlp-insecure",
+		Value:  true,
+		Desc:   "Export traces over plain HTTP rather than HTTPS",
+		EnvVar: "OTLP_INSECURE",
+	})
+	tracingSampleRatio := app.String(cli.StringOpt{
+		Name:   "tracing-sample-ratio",
+		Value:  "1",
+		Desc:   "Fraction of new traces to sample, between 0 and 1. Sampling decisions from incoming trace headers are honoured",
+		EnvVar: "TRACING_SAMPLE_RATIO",
+	})
 	app.Action = func() {
-		log.Infof("public-concordances-api will listen on port: %s, connecting to: %s", *port, *neoURL)
-		runServer(*neoURL, *port, *cacheDuration, *env, *healthcheckInterval, *batchSize)
+		startupSettings := map[string]interface{}{
+			"HEALTHCHECK_INTERVAL":   *healthcheckInterval,
+			"CACHE_DURATION":         *cacheDuration,
+			"NEO_URL":                *neoURL,
+			"GRPC_PORT":              *grpcPort,
+			"LOG_LEVEL":              *logLevel,
+			"SLOW_QUERY_THRESHOLD":   *slowQueryThreshold,
+			"NEO_MAX_ATTEMPTS":       *neoMaxAttempts,
+			"SNAPSHOT_FILE":          *snapshotFile,
+			"TRACING_ENABLED":        *tracingEnabled,
+			"OTLP_ENDPOINT":          *otlpEndpoint,
+			"AUTH_CLIENTS_FILE":      *authClientsFile,
+			"JWKS_FILE":              *jwksFile,
+			"VISIBILITY_POLICY_FILE": *visibilityPolicyFile,
+			"CONFIG_FILE":            *configFile,
+			"RATE_LIMIT":             *rateLimit,
+			"RATE_LIMIT_BURST":       *rateLimitBurst,
+			"MAX_CONCURRENT_QUERIES": *maxConcurrentQueries,
+			"MAX_IDS_PER_REQUEST":    *maxIDsPerRequest,
+			"FALLBACK_NEO_URL":       *fallbackNeoURL,
+			"STALE_CACHE_ENTRIES":    *staleCacheEntries,
+		}
+		log.WithFields(startupSettings).Info("Starting app with arguments")
+
+		shutdownTracing, err := initTracing(*tracingEnabled, *appSystemCode, *otlpEndpoint, *otlpInsecure, *tracingSampleRatio)
+		if err != nil {
+			log.Fatalf("Failed to initialise tracing: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.WithError(err).Warn("Failed to flush traces")
+			}
+		}()
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		handlerOpts := []concordances.HandlerOption{concordances.WithMaxIDsPerRequest(*maxIDsPerRequest)}
+		if *debugPayloadSampleRate > 0 {
+			handlerOpts = append(handlerOpts, concordances.WithDebugPayloadSampleRate(uint64(*debugPayloadSampleRate)))
+		}
+
+		authenticator, err := newAuthenticator(*authClientsFile, *jwksFile, *jwtIssuer, *jwtAudience)
+		if err != nil {
+			log.Fatalf("Failed to configure authentication: %v", err)
+		}
+
+		policies, err := newPolicyStore(*visibilityPolicyFile, *policyReloadInterval)
+		if err != nil {
+			log.Fatalf("Failed to load visibility policy: %v", err)
+		}
+
+		log.Infof("public-concordances-api will listen on port: %s, gRPC port: %s, connecting to: %s", *port, *grpcPort, *neoURL)
+		runServer(ctx, *neoURL, *port, *grpcPort, *env, *healthcheckInterval, *batchSize, *slowQueryThreshold, *profileSlowQueries, *createMissingIndexes, handlerOpts, runtimeSettings{
+			configFile:     *configFile,
+			reloadInterval: *configReloadInterval,
+			defaults: concordances.RuntimeConfig{
+				CacheDuration:  *cacheDuration,
+				LogLevel:       *logLevel,
+				RateLimit:      rateLimit,
+				RateLimitBurst: rateLimitBurst,
+			},
+			startup: startupSettings,
+		}, snapshotSettings{
+			file:           *snapshotFile,
+			reloadInterval: *snapshotReloadInterval,
+		}, retrySettings{
+			maxAttempts: *neoMaxAttempts,
+			backoff:     *neoRetryBackoff,
+			maxBackoff:  *neoRetryMaxBackoff,
+		}, routingSettings{
+			strategy:   *neoRouting,
+			ejectAfter: *neoEjectAfter,
+			ejectFor:   *neoEjectFor,
+		}, fallbackSettings{
+			neoURL:            *fallbackNeoURL,
+			staleCacheEntries: *staleCacheEntries,
+			breakerThreshold:  *breakerThreshold,
+			openDuration:      *breakerOpenDuration,
+		}, authenticator, policies, serverLimits{
+			rateLimiter:          concordances.NewRateLimiter(float64(*rateLimit), *rateLimitBurst),
+			maxConcurrentQueries: *maxConcurrentQueries,
+			maxQueuedQueries:     *maxQueuedQueries,
+			queryQueueTimeout:    *queryQueueTimeout,
+		})
 	}
+	app.Command("snapshot", "Offline snapshots of the concordances, for serving without Neo4j", snapshotCommands(neoURL, batchSize))
 
 	log.InitLogger(*appSystemCode, *logLevel)
-	log.WithFields(map[string]interface{}{
-		"HEALTHCHECK_INTERVAL": *healthcheckInterval,
-		"CACHE_DURATION":       *cacheDuration,
-		"NEO_URL":              *neoURL,
-		"LOG_LEVEL":            *logLevel,
-	}).Info("Starting app with arguments")
 	app.Run(os.Args)
 }
 
-func runServer(neoURL string, port string, cacheDuration string, env string, healthcheckInterval string, batchSize int) {
+// shutdownTimeout bounds how long in-flight requests are given to finish, and traces to be flushed, on SIGTERM
+const shutdownTimeout = 10 * time.Second
+
+// serverLimits protect Neo4j from being saturated by a few heavy callers
+type serverLimits struct {
+	rateLimiter          *concordances.RateLimiter
+	maxConcurrentQueries int
+	maxQueuedQueries     int
+	queryQueueTimeout    string
+}
+
+// runtimeSettings are the settings that can be changed while running, by editing the config file
+type runtimeSettings struct {
+	configFile     string
+	reloadInterval string
+	defaults       concordances.RuntimeConfig
+	startup        map[string]interface{}
+}
 
-	if duration, durationErr := time.ParseDuration(cacheDuration); durationErr != nil {
-		log.Fatalf("Failed to parse cache duration string, %v", durationErr)
+// snapshotSettings configure serving from a snapshot file instead of Neo4j
+type snapshotSettings struct {
+	file           string
+	reloadInterval string
+}
+
+// retrySettings configure how Cypher queries failing with transient errors are retried
+type retrySettings struct {
+	maxAttempts int
+	backoff     string
+	maxBackoff  string
+}
+
+// routingSettings configure how queries are spread across several Neo4j URLs
+type routingSettings struct {
+	strategy   string
+	ejectAfter int
+	ejectFor   string
+}
+
+// fallbackSettings configure what concordances are answered from when Neo4j is failing
+type fallbackSettings struct {
+	neoURL            string
+	staleCacheEntries int
+	breakerThreshold  int
+	openDuration      string
+}
+
+// runServer serves the REST and gRPC APIs until ctx is done, then lets in-flight requests finish before returning
+func runServer(ctx context.Context, neoURL string, port string, grpcPort string, env string, healthcheckInterval string, batchSize int, slowQueryThreshold string, profileSlowQueries bool, createMissingIndexes bool, handlerOpts []concordances.HandlerOption, runtime runtimeSettings, snapshot snapshotSettings, retry retrySettings, routing routingSettings, fallback fallbackSettings, authenticator concordances.Authenticator, policies *concordances.PolicyStore, limits serverLimits) {
+	var concordanceDriver concordances.Driver
+	if snapshot.file != "" {
+		concordanceDriver = newSnapshotDriver(snapshot, env)
 	} else {
-		concordances.CacheControlHeader = fmt.Sprintf("max-age=%s, public", strconv.FormatFloat(duration.Seconds(), 'f', 0, 64))
+		concordanceDriver = newNeoDriver(neoURL, env, batchSize, slowQueryThreshold, profileSlowQueries, createMissingIndexes, retry, routing, fallback, limits)
 	}
+	handler := concordances.NewHandler(concordanceDriver, handlerOpts...)
 
-	conf := neoutils.ConnectionConfig{
-		BatchSize:     batchSize,
-		Transactional: false,
-		HTTPClient: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost: 100,
-			},
-			Timeout: 1 * time.Minute,
-		},
-		BackgroundConnect: true,
-	}
-	db, err := neoutils.Connect(neoURL, &conf)
+	config, err := newConfigStore(runtime, handler, limits.rateLimiter)
 	if err != nil {
-		log.Fatalf("Error connecting to neo4j %s", err)
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	concordances.ConcordanceDriver = concordances.NewCypherDriver(db, env)
-
 	checkInterval, err := time.ParseDuration(healthcheckInterval)
 	if err != nil {
 		checkInterval = time.Second * 30
 	}
-	concordances.StartAsyncChecker(checkInterval)
+	stopChecker := handler.StartAsyncChecker(checkInterval)
+	defer stopChecker()
+
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatalf("Unable to listen on gRPC port: %v", err)
+	}
+	grpcServer := concordances.NewGRPCServer(handler, checkInterval, authenticator, policies)
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Unable to start gRPC server: %v", err)
+		}
+	}()
 
 	servicesRouter := mux.NewRouter()
 
 	// Then API specific ones:
-
-	mh := &handlers.MethodHandler{
-		"GET": http.HandlerFunc(concordances.GetConcordances),
-	}
-	servicesRouter.Handle("/concordances", mh)
+	handler.RegisterRoutes(servicesRouter)
 
 	var monitoringRouter http.Handler = servicesRouter
-	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log.Logger(), monitoringRouter)
+	monitoringRouter = concordances.RateLimitMiddleware(limits.rateLimiter, monitoringRouter)
+	if policies != nil {
+		monitoringRouter = concordances.PolicyMiddleware(policies, monitoringRouter)
+	}
+	if authenticator != nil {
+		monitoringRouter = concordances.AuthMiddleware(authenticator, monitoringRouter)
+	}
 	monitoringRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, monitoringRouter)
 
 	// The top one of these feels more correct, but the lower one matches what we have in Dropwizard,
@@ -136,13 +480,215 @@ func runServer(neoURL string, port string, cacheDuration string, env string, hea
 	http.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
 	http.HandleFunc(status.BuildInfoPathDW, status.BuildInfoHandler)
 
-	http.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(concordances.GTG))
-	http.HandleFunc("/__health", fthealth.Handler(concordances.HealthCheck()))
+	http.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(handler.GTG))
+	http.HandleFunc("/__health", fthealth.Handler(handler.HealthCheck()))
+	http.HandleFunc("/__api", apiHandler)
+	var configHandler http.Handler = http.HandlerFunc(config.ConfigHandler)
+	if authenticator != nil {
+		// The config names the Neo4j hosts and the limits in force, so it is only shown to the API's clients
+		configHandler = concordances.AuthMiddleware(authenticator, configHandler)
+	}
+	http.Handle("/__config", configHandler)
 
 	http.Handle("/", monitoringRouter)
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Unable to start server: %v", err)
+	server := &http.Server{Addr: ":" + port}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Unable to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("Shutting down, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Warn("Timed out waiting for in-flight requests")
+	}
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+}
+
+// newNeoDriver connects to Neo4j, spreading queries across several URLs if it's given a list, with a fallback for
+// when it fails
+func newNeoDriver(neoURL string, env string, batchSize int, slowQueryThreshold string, profileSlowQueries bool, createMissingIndexes bool, retry retrySettings, routing routingSettings, fallback fallbackSettings, limits serverLimits) concordances.Driver {
+	conf := neoConnectionConfig(batchSize)
+	slowQueryDuration, err := time.ParseDuration(slowQueryThreshold)
+	if err != nil {
+		log.Fatalf("Failed to parse slow query threshold, %v", err)
+	}
+	retryBackoff, err := time.ParseDuration(retry.backoff)
+	if err != nil {
+		log.Fatalf("Failed to parse Neo4j retry backoff, %v", err)
+	}
+	retryMaxBackoff, err := time.ParseDuration(retry.maxBackoff)
+	if err != nil {
+		log.Fatalf("Failed to parse Neo4j retry max backoff, %v", err)
+	}
+	retryPolicy := concordances.RetryPolicy{MaxAttempts: retry.maxAttempts, InitialBackoff: retryBackoff, MaxBackoff: retryMaxBackoff}
+	driverOpts := func(url string) []concordances.CypherDriverOption {
+		opts := []concordances.CypherDriverOption{concordances.WithSlowQueryLog(slowQueryDuration), concordances.WithRetryPolicy(retryPolicy)}
+		if profileSlowQueries {
+			opts = append(opts, concordances.WithQueryProfiling(url, conf.HTTPClient))
+		}
+		return opts
+	}
+
+	var endpoints []concordances.Endpoint
+	for i, url := range strings.Split(neoURL, ",") {
+		url = strings.TrimSpace(url)
+		db, err := neoutils.Connect(url, &conf)
+		if err != nil {
+			log.Fatalf("Error connecting to neo4j %s", err)
+		}
+		driver := concordances.NewCypherDriver(db, env, driverOpts(url)...)
+		if createMissingIndexes && i == 0 {
+			if err := driver.CreateMissingIndexes(); err != nil {
+				log.WithError(err).Error("Failed to create missing Neo4j indexes")
+			}
+		}
+		endpoints = append(endpoints, concordances.Endpoint{Name: concordances.EndpointName(url), Driver: driver})
+	}
+	var driver concordances.Driver = endpoints[0].Driver
+	if len(endpoints) > 1 {
+		strategy, ok := concordances.ParseRoutingStrategy(routing.strategy)
+		if !ok {
+			log.Fatalf("Unknown Neo4j routing strategy %s", routing.strategy)
+		}
+		ejectFor, err := time.ParseDuration(routing.ejectFor)
+		if err != nil {
+			log.Fatalf("Failed to parse Neo4j ejection duration, %v", err)
+		}
+		driver, err = concordances.NewRoutingDriver(endpoints, concordances.WithRoutingStrategy(strategy), concordances.WithEjection(routing.ejectAfter, ejectFor))
+		if err != nil {
+			log.Fatalf("Failed to route Neo4j queries, %v", err)
+		}
+	}
+	limitQueries := func(driver concordances.Driver) concordances.Driver {
+		if limits.maxConcurrentQueries <= 0 {
+			return driver
+		}
+		queueTimeout, err := time.ParseDuration(limits.queryQueueTimeout)
+		if err != nil {
+			log.Fatalf("Failed to parse query queue timeout, %v", err)
+		}
+		return concordances.NewConcurrencyLimitedDriver(driver, limits.maxConcurrentQueries, limits.maxQueuedQueries, queueTimeout)
+	}
+	concordanceDriver := limitQueries(driver)
+
+	var secondary concordances.Driver
+	switch {
+	case fallback.neoURL != "":
+		replica, err := neoutils.Connect(fallback.neoURL, &conf)
+		if err != nil {
+			log.Fatalf("Error connecting to fallback neo4j %s", err)
+		}
+		secondary = limitQueries(concordances.NewCypherDriver(replica, env, driverOpts(fallback.neoURL)...))
+	case fallback.staleCacheEntries > 0:
+		secondary = concordances.NewStaleCache(fallback.staleCacheEntries)
+	}
+	if secondary != nil {
+		openDuration, err := time.ParseDuration(fallback.openDuration)
+		if err != nil {
+			log.Fatalf("Failed to parse circuit breaker open duration, %v", err)
+		}
+		concordanceDriver = concordances.NewFallbackDriver(concordanceDriver, secondary,
+			concordances.WithBreakerThreshold(fallback.breakerThreshold), concordances.WithBreakerOpenDuration(openDuration))
+	}
+	return concordanceDriver
+}
+
+func neoConnectionConfig(batchSize int) neoutils.ConnectionConfig {
+	return neoutils.ConnectionConfig{
+		BatchSize:     batchSize,
+		Transactional: false,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 100,
+			},
+			Timeout: 1 * time.Minute,
+		},
+		BackgroundConnect: true,
+	}
+}
+
+// newSnapshotDriver serves the snapshot file instead of Neo4j, reloading it when it changes
+func newSnapshotDriver(snapshot snapshotSettings, env string) concordances.Driver {
+	interval, err := time.ParseDuration(snapshot.reloadInterval)
+	if err != nil {
+		log.Fatalf("Failed to parse snapshot reload interval, %v", err)
+	}
+	driver, err := concordances.NewSnapshotDriver(snapshot.file, env)
+	if err != nil {
+		log.Fatalf("Failed to load concordances snapshot: %v", err)
+	}
+	log.WithField("path", snapshot.file).WithField("created_at", driver.CreatedAt()).Info("Serving concordances from a snapshot rather than Neo4j")
+	driver.Watch(interval)
+	return driver
+}
+
+// newAuthenticator returns nil, leaving the API open, if no clients file is given
+func newAuthenticator(clientsFile string, jwksFile string, issuer string, audience string) (concordances.Authenticator, error) {
+	if clientsFile == "" {
+		if jwksFile != "" {
+			return nil, errors.New("JWKS_FILE requires AUTH_CLIENTS_FILE to identify the clients tokens are issued to")
+		}
+		log.Warn("No AUTH_CLIENTS_FILE given, API requests will not be authenticated")
+		return nil, nil
 	}
 
+	clients, err := concordances.LoadClients(clientsFile)
+	if err != nil {
+		return nil, err
+	}
+	chain := concordances.AuthenticatorChain{concordances.NewAPIKeyAuthenticator(clients)}
+	if jwksFile != "" {
+		jwtAuthenticator, err := concordances.NewJWTAuthenticator(jwksFile, clients, issuer, audience)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwtAuthenticator)
+	}
+	return chain, nil
+}
+
+// newPolicyStore returns nil, leaving every authority visible, if no policy file is given
+func newPolicyStore(policyFile string, reloadInterval string) (*concordances.PolicyStore, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+	interval, err := time.ParseDuration(reloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy reload interval, %v", err)
+	}
+	policies, err := concordances.NewPolicyStore(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	policies.Watch(interval)
+	return policies, nil
+}
+
+// newConfigStore applies the startup settings, overridden by the config file if one is given, and watches it for changes
+func newConfigStore(runtime runtimeSettings, handler *concordances.Handler, rateLimiter *concordances.RateLimiter) (*concordances.ConfigStore, error) {
+	interval, err := time.ParseDuration(runtime.reloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config reload interval, %v", err)
+	}
+	config, err := concordances.NewConfigStore(runtime.configFile, runtime.defaults, handler, rateLimiter, runtime.startup)
+	if err != nil {
+		return nil, err
+	}
+	config.Watch(interval)
+	return config, nil
 }
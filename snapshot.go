@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	log "github.com/Financial-Times/go-logger"
+	"github.com/Financial-Times/neo-utils-go/neoutils"
+	"github.com/Financial-Times/public-concordances-api/concordances"
+	"github.com/jawher/mow.cli"
+)
+
+// snapshotRetryPolicy waits longer between retries than requests can, as a failed page fails the whole build
+var snapshotRetryPolicy = concordances.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// snapshotCommands manage the snapshot files a SNAPSHOT_FILE replica serves
+func snapshotCommands(neoURL *string, batchSize *int) func(*cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		cmd.Command("build", "Write every concordance in Neo4j to a snapshot file, with a manifest alongside it", func(cmd *cli.Cmd) {
+			output := cmd.String(cli.StringOpt{
+				Name:   "output",
+				Value:  "concordances.snapshot",
+				Desc:   "Path of the snapshot file, which is only replaced once the new snapshot is complete. The manifest is written to the same path with a .manifest.json suffix",
+				EnvVar: "SNAPSHOT_OUTPUT",
+			})
+			pageSize := cmd.Int(cli.IntOpt{
+				Name:   "page-size",
+				Value:  10000,
+				Desc:   "Canonical concepts read from Neo4j per query",
+				EnvVar: "SNAPSHOT_PAGE_SIZE",
+			})
+			cmd.Action = func() {
+				if err := buildSnapshot(*neoURL, *batchSize, *output, *pageSize); err != nil {
+					log.Fatalf("Failed to build concordances snapshot: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// buildSnapshot reads from the first of the NEO_URLs, as they should all hold the same concordances
+func buildSnapshot(neoURL string, batchSize int, output string, pageSize int) error {
+	url := strings.TrimSpace(strings.Split(neoURL, ",")[0])
+	conf := neoConnectionConfig(batchSize)
+	conf.BackgroundConnect = false
+	db, err := neoutils.Connect(url, &conf)
+	if err != nil {
+		return err
+	}
+	driver := concordances.NewCypherDriver(db, "", concordances.WithRetryPolicy(snapshotRetryPolicy))
+	// Without the prefUUID index every page sorts all the concepts in Neo4j
+	if err := driver.CheckSnapshotIndexes(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	manifest, err := concordances.WriteSnapshotFile(output, start, func(w *concordances.SnapshotWriter) error {
+		return driver.BuildSnapshot(context.Background(), w, pageSize)
+	})
+	if err != nil {
+		return err
+	}
+	if err := concordances.WriteSnapshotManifestFile(output+".manifest.json", manifest); err != nil {
+		return err
+	}
+
+	log.WithFields(map[string]interface{}{
+		"path":        output,
+		"concepts":    manifest.Concepts,
+		"authorities": manifest.Authorities,
+		"sha256":      manifest.SHA256,
+		"duration":    time.Since(start).String(),
+	}).Info("Built concordances snapshot")
+	return nil
+}